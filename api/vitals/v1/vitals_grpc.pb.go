@@ -0,0 +1,259 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: api/vitals/v1/vitals.proto
+
+// Vitals serves the status records of k8sClusterVitals, the same ones as the /healthcheck/v1 http api.
+// regenerate the go code with: make proto
+
+package vitalsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Vitals_List_FullMethodName   = "/k8sclustervitals.vitals.v1.Vitals/List"
+	Vitals_Get_FullMethodName    = "/k8sclustervitals.vitals.v1.Vitals/Get"
+	Vitals_Watch_FullMethodName  = "/k8sclustervitals.vitals.v1.Vitals/Watch"
+	Vitals_Triage_FullMethodName = "/k8sclustervitals.vitals.v1.Vitals/Triage"
+)
+
+// VitalsClient is the client API for Vitals service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VitalsClient interface {
+	// List returns the unhealthy resources matching the filter
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Get returns the status of a single monitored resource, healthy ones included
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// Watch sends a snapshot of the unhealthy resources matching the filter followed by their state changes
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Vitals_WatchClient, error)
+	// Triage points the unhealthy resources matching the filter at their root causes
+	Triage(ctx context.Context, in *TriageRequest, opts ...grpc.CallOption) (*TriageResponse, error)
+}
+
+type vitalsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVitalsClient(cc grpc.ClientConnInterface) VitalsClient {
+	return &vitalsClient{cc}
+}
+
+func (c *vitalsClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, Vitals_List_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitalsClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, Vitals_Get_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitalsClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Vitals_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Vitals_ServiceDesc.Streams[0], Vitals_Watch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vitalsWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Vitals_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type vitalsWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *vitalsWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *vitalsClient) Triage(ctx context.Context, in *TriageRequest, opts ...grpc.CallOption) (*TriageResponse, error) {
+	out := new(TriageResponse)
+	err := c.cc.Invoke(ctx, Vitals_Triage_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VitalsServer is the server API for Vitals service.
+// All implementations must embed UnimplementedVitalsServer
+// for forward compatibility
+type VitalsServer interface {
+	// List returns the unhealthy resources matching the filter
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Get returns the status of a single monitored resource, healthy ones included
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Watch sends a snapshot of the unhealthy resources matching the filter followed by their state changes
+	Watch(*WatchRequest, Vitals_WatchServer) error
+	// Triage points the unhealthy resources matching the filter at their root causes
+	Triage(context.Context, *TriageRequest) (*TriageResponse, error)
+	mustEmbedUnimplementedVitalsServer()
+}
+
+// UnimplementedVitalsServer must be embedded to have forward compatible implementations.
+type UnimplementedVitalsServer struct {
+}
+
+func (UnimplementedVitalsServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedVitalsServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedVitalsServer) Watch(*WatchRequest, Vitals_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedVitalsServer) Triage(context.Context, *TriageRequest) (*TriageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Triage not implemented")
+}
+func (UnimplementedVitalsServer) mustEmbedUnimplementedVitalsServer() {}
+
+// UnsafeVitalsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VitalsServer will
+// result in compilation errors.
+type UnsafeVitalsServer interface {
+	mustEmbedUnimplementedVitalsServer()
+}
+
+func RegisterVitalsServer(s grpc.ServiceRegistrar, srv VitalsServer) {
+	s.RegisterService(&Vitals_ServiceDesc, srv)
+}
+
+func _Vitals_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VitalsServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Vitals_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VitalsServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Vitals_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VitalsServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Vitals_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VitalsServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Vitals_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VitalsServer).Watch(m, &vitalsWatchServer{stream})
+}
+
+type Vitals_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type vitalsWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *vitalsWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Vitals_Triage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TriageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VitalsServer).Triage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Vitals_Triage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VitalsServer).Triage(ctx, req.(*TriageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Vitals_ServiceDesc is the grpc.ServiceDesc for Vitals service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Vitals_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "k8sclustervitals.vitals.v1.Vitals",
+	HandlerType: (*VitalsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "List",
+			Handler:    _Vitals_List_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _Vitals_Get_Handler,
+		},
+		{
+			MethodName: "Triage",
+			Handler:    _Vitals_Triage_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Vitals_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/vitals/v1/vitals.proto",
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	k8client "github.com/vivekganesan01/k8sClusterVitals/internals"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// authentication and authorization of the http api and the Vitals grpc service, every mode is optional and off by default:
+//
+//	AUTH_TOKEN_REVIEW=true            bearer tokens, eg: service account tokens, are reviewed with a TokenReview
+//	AUTH_API_KEYS_FILE=<path>         static api keys, a csv of key,user[,uid[,"group1,group2"]] like the kube-apiserver token file
+//	AUTH_MTLS=true                    client certificates verified by the client CA of the https server, user from the CN, groups from O
+//	AUTH_SUBJECT_ACCESS_REVIEW=true   callers only see the namespaces they can get deployments in, silences need create or
+//	                                  delete on silences.k8sclustervitals.io
+//	AUTH_OPEN_PATHS                   comma separated routes served without authentication, defaults to openPaths
+type authConfig struct {
+	tokenReview bool
+	apiKeys     map[[sha256.Size]byte]k8client.Identity // keyed by the sha256 of the key
+	mtls        bool
+	authorize   bool
+	open        map[string]bool
+	reviewer    *k8client.AccessReviewer
+}
+
+// probes, health verdicts and static assets stay open, load balancers and kubelets do not authenticate
+var openPaths = []string{
+	"/readiness",
+	"/healthcheck/v1/health",
+	"/healthcheck/v1/namespaces/:namespace/health",
+	"/healthcheck/v1/groups/:name/health",
+	apiV2 + "/health",
+	apiV2 + "/namespaces/:namespace/health",
+	apiV2 + "/groups/:name/health",
+	"/openapi.json",
+	"/dashboard",
+	"/dashboard/*",
+}
+
+// routes which change the silences, authorized with the verb on silences.k8sclustervitals.io rather than read access
+var silenceRoutes = map[string]string{
+	http.MethodPost + " /healthcheck/v1/silences":       "create",
+	http.MethodDelete + " /healthcheck/v1/silences/:id": "delete",
+	http.MethodPost + " " + apiV2 + "/silences":         "create",
+	http.MethodDelete + " " + apiV2 + "/silences/:id":   "delete",
+}
+
+// routes which are not scoped to a namespace, with authorization they need access to every namespace
+var clusterScopedPaths = map[string]bool{
+	"/metrics":                             true,
+	"/healthcheck/v1/clusters":             true,
+	"/healthcheck/v1/groups":               true,
+	"/healthcheck/v1/silences":             true,
+	"/healthcheck/v1/silences/:id":         true,
+	"/healthcheck/v1/scrape_configuration": true,
+	apiV2 + "/clusters":                    true,
+	apiV2 + "/groups":                      true,
+	apiV2 + "/silences":                    true,
+	apiV2 + "/silences/:id":                true,
+	apiV2 + "/scrape_configuration":        true,
+}
+
+const identityKey = "identity"
+
+// auth is nil unless authentication is enabled
+var auth *authConfig
+
+// loadAuthConfig reads the configuration from the environment, nil when no authentication mode is enabled
+func loadAuthConfig() (*authConfig, error) {
+	config := &authConfig{
+		tokenReview: os.Getenv("AUTH_TOKEN_REVIEW") == "true",
+		mtls:        os.Getenv("AUTH_MTLS") == "true",
+		authorize:   os.Getenv("AUTH_SUBJECT_ACCESS_REVIEW") == "true",
+		open:        make(map[string]bool),
+	}
+	if path := os.Getenv("AUTH_API_KEYS_FILE"); path != "" {
+		keys, err := loadAPIKeys(path)
+		if err != nil {
+			return nil, err
+		}
+		config.apiKeys = keys
+	}
+	if !config.tokenReview && !config.mtls && config.apiKeys == nil {
+		if config.authorize {
+			return nil, errors.New("AUTH_SUBJECT_ACCESS_REVIEW needs an authentication mode")
+		}
+		return nil, nil
+	}
+	paths := openPaths
+	if v, ok := os.LookupEnv("AUTH_OPEN_PATHS"); ok {
+		paths = strings.Split(v, ",")
+	}
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); path != "" {
+			config.open[path] = true
+		}
+	}
+	if config.tokenReview || config.authorize {
+		reviewer, err := k8client.NewAccessReviewer()
+		if err != nil {
+			return nil, err
+		}
+		config.reviewer = reviewer
+	}
+	return config, nil
+}
+
+// loadAPIKeys parses the api keys file, eg: 3f9c...,ci-pipeline,,"vitals-readers"
+func loadAPIKeys(path string) (map[[sha256.Size]byte]k8client.Identity, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[[sha256.Size]byte]k8client.Identity, len(records))
+	for i, record := range records {
+		if len(record) < 2 || record[0] == "" || record[1] == "" {
+			return nil, errors.New(helpers.LogMsg("line ", strconv.Itoa(i+1), " of ", path, " needs at least a key and a user"))
+		}
+		identity := k8client.Identity{Username: record[1], Method: "apikey"}
+		if len(record) > 2 {
+			identity.UID = record[2]
+		}
+		if len(record) > 3 && record[3] != "" {
+			identity.Groups = strings.Split(record[3], ",")
+		}
+		keys[sha256.Sum256([]byte(record[0]))] = identity
+	}
+	return keys, nil
+}
+
+// middleware authenticates every request outside of the open paths and checks the access to cluster scoped routes
+func (a *authConfig) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if a.open[c.Path()] {
+				return next(c)
+			}
+			identity, err := a.authenticate(c)
+			if err != nil {
+				if errors.Is(err, errUnauthenticated) || errors.Is(err, k8client.ErrTokenRejected) {
+					c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="k8sclustervitals"`)
+					return apiFailOrText(c, http.StatusUnauthorized, "unauthenticated", "valid credentials are required")
+				}
+				log.Error().Str("caller", "auth.go").Msg(helpers.LogMsg("failed to review token: ", err.Error()))
+				return apiFailOrText(c, http.StatusServiceUnavailable, codeUnavailable, "unable to review the credentials")
+			}
+			c.Set(identityKey, identity)
+			if verb, ok := silenceRoutes[c.Request().Method+" "+c.Path()]; a.authorize && ok {
+				allowed, err := a.reviewer.CanManageSilences(c.Request().Context(), identity, verb)
+				if err != nil {
+					log.Error().Str("caller", "auth.go").Msg(helpers.LogMsg("failed to review access of ", identity.Username, ": ", err.Error()))
+					return apiFailOrText(c, http.StatusServiceUnavailable, codeUnavailable, "unable to review the access")
+				}
+				if !allowed {
+					return apiFailOrText(c, http.StatusForbidden, "permission_denied", helpers.LogMsg(identity.Username, " cannot ", verb, " silences.", k8client.SilencesGroup))
+				}
+			} else if a.authorize && clusterScopedPaths[c.Path()] {
+				allowed, err := a.reviewer.CanGetDeployments(c.Request().Context(), identity, "")
+				if err != nil {
+					log.Error().Str("caller", "auth.go").Msg(helpers.LogMsg("failed to review access of ", identity.Username, ": ", err.Error()))
+					return apiFailOrText(c, http.StatusServiceUnavailable, codeUnavailable, "unable to review the access")
+				}
+				if !allowed {
+					return apiFailOrText(c, http.StatusForbidden, "permission_denied", helpers.LogMsg(identity.Username, " cannot get deployments in every namespace"))
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+var errUnauthenticated = errors.New("unauthenticated")
+
+// authenticate reads the credentials of a http request
+func (a *authConfig) authenticate(c echo.Context) (k8client.Identity, error) {
+	return a.authenticateCredentials(c.Request().Context(), c.Request().TLS, c.Request().Header.Get("X-API-Key"), c.Request().Header.Get(echo.HeaderAuthorization))
+}
+
+// authenticateCredentials tries a verified client certificate, then the api keys, then a token review
+func (a *authConfig) authenticateCredentials(ctx context.Context, state *tls.ConnectionState, apiKey string, authorization string) (k8client.Identity, error) {
+	if a.mtls && state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		subject := state.VerifiedChains[0][0].Subject
+		return k8client.Identity{Username: subject.CommonName, Groups: subject.Organization, Method: "mtls"}, nil
+	}
+	token := apiKey
+	if token == "" && strings.HasPrefix(authorization, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	}
+	if token == "" {
+		return k8client.Identity{}, errUnauthenticated
+	}
+	if identity, ok := a.apiKeys[sha256.Sum256([]byte(token))]; ok {
+		return identity, nil
+	}
+	if !a.tokenReview {
+		return k8client.Identity{}, errUnauthenticated
+	}
+	return a.reviewer.ReviewToken(ctx, token)
+}
+
+// anonymous is true for the requests served on an open path while authentication is enabled, they only get verdicts
+func anonymous(c echo.Context) bool {
+	if auth == nil {
+		return false
+	}
+	_, ok := c.Get(identityKey).(k8client.Identity)
+	return !ok
+}
+
+// groupVerdict strips the members of a group status, they name resources of every namespace
+func groupVerdict(status helpers.GroupStatus) helpers.GroupStatus {
+	return helpers.GroupStatus{Name: status.Name, Cluster: status.Cluster, Policy: status.Policy, Status: status.Status, Members: []helpers.GroupMemberStatus{}}
+}
+
+// namespaceAuthorizer narrows the status filters of the request down to the namespaces its caller can get deployments in,
+// nil when every namespace is visible
+func namespaceAuthorizer(c echo.Context) func(namespace string) bool {
+	if auth == nil || !auth.authorize {
+		return nil
+	}
+	identity, ok := c.Get(identityKey).(k8client.Identity)
+	if !ok {
+		return nil // open paths
+	}
+	return identityAuthorizer(c.Request().Context(), identity)
+}
+
+// identityAuthorizer tells the namespaces the identity can get deployments in, nil when every namespace is visible
+func identityAuthorizer(ctx context.Context, identity k8client.Identity) func(namespace string) bool {
+	if all, err := auth.reviewer.CanGetDeployments(ctx, identity, ""); err == nil && all {
+		return nil
+	}
+	return func(namespace string) bool {
+		allowed, err := auth.reviewer.CanGetDeployments(ctx, identity, namespace)
+		if err != nil {
+			log.Error().Str("caller", "auth.go").Msg(helpers.LogMsg("failed to review access of ", identity.Username, " to ", namespace, ": ", err.Error()))
+		}
+		return err == nil && allowed
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/vivekganesan01/k8sClusterVitals/dashboard"
+	k8client "github.com/vivekganesan01/k8sClusterVitals/internals"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestLoadAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-keys.csv")
+	content := "# key,user,uid,groups\ns3cret,ci-pipeline,1001,\"vitals-readers,ci\"\nother,ops\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := loadAPIKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci := keys[sha256.Sum256([]byte("s3cret"))]
+	if ci.Username != "ci-pipeline" || ci.UID != "1001" || strings.Join(ci.Groups, ",") != "vitals-readers,ci" {
+		t.Fatalf("identity %+v", ci)
+	}
+	if ops := keys[sha256.Sum256([]byte("other"))]; ops.Username != "ops" || ops.UID != "" {
+		t.Fatalf("identity %+v", ops)
+	}
+}
+
+// an open group health path only carries the verdict, the members name resources of every namespace
+func TestAnonymousGroupVerdict(t *testing.T) {
+	previous := auth
+	t.Cleanup(func() { auth = previous })
+	auth = &authConfig{open: map[string]bool{apiV2 + "/groups/:name/health": true}}
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, apiV2+"/groups/checkout/health", nil), nil)
+	if !anonymous(c) {
+		t.Fatal("request without identity is not anonymous")
+	}
+	status := groupVerdict(helpers.GroupStatus{Name: "checkout", Policy: helpers.GroupPolicyAll, Status: "not_ok", Reason: "1/2 healthy",
+		Members: []helpers.GroupMemberStatus{{Resource: "deployment/payments/api", State: helpers.StateDegraded, Reason: "3/6 available"}}})
+	if len(status.Members) != 0 || status.Reason != "" || status.Status != "not_ok" {
+		t.Fatalf("verdict %+v", status)
+	}
+	c.Set(identityKey, k8client.Identity{Username: "ci"})
+	if anonymous(c) {
+		t.Fatal("authenticated request is anonymous")
+	}
+	auth = nil
+	c.Set(identityKey, nil)
+	if anonymous(c) {
+		t.Fatal("requests are anonymous with authentication disabled")
+	}
+}
+
+// newAuthenticatedAPI serves the v2 api and the dashboard behind the middleware with an api key for ci, the subject
+// access reviews allow the deployment reads and silence verbs for which allow returns true
+func newAuthenticatedAPI(t *testing.T, allow func(resource, verb string) bool) (*echo.Echo, *[]authorizationv1.ResourceAttributes) {
+	t.Helper()
+	client := fake.NewSimpleClientset()
+	var reviews []authorizationv1.ResourceAttributes
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		reviews = append(reviews, *review.Spec.ResourceAttributes)
+		review.Status.Allowed = allow(review.Spec.ResourceAttributes.Resource, review.Spec.ResourceAttributes.Verb)
+		return true, review, nil
+	})
+	previous := auth
+	t.Cleanup(func() { auth = previous })
+	auth = &authConfig{
+		apiKeys:   map[[sha256.Size]byte]k8client.Identity{sha256.Sum256([]byte("s3cret")): {Username: "ci", Method: "apikey"}},
+		authorize: true,
+		open:      make(map[string]bool),
+		reviewer:  k8client.NewAccessReviewerForClient(client),
+	}
+	for _, path := range openPaths {
+		auth.open[path] = true
+	}
+	e := echo.New()
+	e.Use(auth.middleware())
+	registerAPIv2(e)
+	dashboard.Register(e)
+	return e, &reviews
+}
+
+func serveAs(e *echo.Echo, method string, target string, credential string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if credential != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+credential)
+	}
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	silence := `{"matchers":{"namespace":"payments"},"duration":"2h","author":"ops"}`
+	readOnly := func(resource, verb string) bool { return resource == "deployments" }
+	tests := []struct {
+		name       string
+		allow      func(resource, verb string) bool
+		method     string
+		target     string
+		credential string
+		body       string
+		status     int
+	}{
+		{"open health", readOnly, http.MethodGet, apiV2 + "/health", "", "", http.StatusOK},
+		{"open dashboard", readOnly, http.MethodGet, "/dashboard/", "", "", http.StatusOK},
+		{"missing credential", readOnly, http.MethodGet, apiV2 + "/status", "", "", http.StatusUnauthorized},
+		{"invalid credential", readOnly, http.MethodGet, apiV2 + "/status", "wrong", "", http.StatusUnauthorized},
+		{"api key", readOnly, http.MethodGet, apiV2 + "/status", "s3cret", "", http.StatusOK},
+		{"cluster scoped allowed", readOnly, http.MethodGet, apiV2 + "/scrape_configuration", "s3cret", "", http.StatusOK},
+		{"cluster scoped denied", func(string, string) bool { return false }, http.MethodGet, apiV2 + "/scrape_configuration", "s3cret", "", http.StatusForbidden},
+		{"silence read", readOnly, http.MethodGet, apiV2 + "/silences", "s3cret", "", http.StatusOK},
+		{"silence create denied to readers", readOnly, http.MethodPost, apiV2 + "/silences", "s3cret", silence, http.StatusForbidden},
+		{"silence create", func(resource, verb string) bool { return resource == "silences" && verb == "create" }, http.MethodPost, apiV2 + "/silences", "s3cret", silence, http.StatusCreated},
+		{"silence delete denied", func(resource, verb string) bool { return verb == "create" }, http.MethodDelete, apiV2 + "/silences/unknown", "s3cret", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seedStore(t)
+			e, reviews := newAuthenticatedAPI(t, tt.allow)
+			rec := serveAs(e, tt.method, tt.target, tt.credential, tt.body)
+			// the verdict of an open path depends on the store, only the middleware is under test
+			if status := rec.Code; status != tt.status && !(tt.credential == "" && tt.status == http.StatusOK && status == http.StatusServiceUnavailable) {
+				t.Fatalf("status %d, want %d: %s", status, tt.status, rec.Body.String())
+			}
+			if tt.status == http.StatusUnauthorized {
+				if rec.Header().Get(echo.HeaderWWWAuthenticate) == "" || !strings.Contains(rec.Body.String(), `"code":"unauthenticated"`) {
+					t.Fatalf("401 without challenge or envelope: %v %s", rec.Header(), rec.Body.String())
+				}
+			}
+			// silence writes are authorized with the verb on silences rather than read access
+			if tt.method != http.MethodGet {
+				if len(*reviews) != 1 || (*reviews)[0].Resource != "silences" || (*reviews)[0].Group != k8client.SilencesGroup {
+					t.Fatalf("reviews %+v, want a single one on silences", *reviews)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+// exit codes of the subcommands
+const (
+	exitOK        = 0
+	exitUnhealthy = 1 // something matching the query is not healthy
+	exitError     = 2 // bad usage or the evaluation could not run
+)
+
+// the label every monitored deployment and statefulset carries
+const scrapeLabelSelector = "k8sclustervitals.io/scrape=true"
+
+type command struct {
+	run   func(args []string, stdout io.Writer) int
+	usage string
+}
+
+var commands = map[string]command{
+	"status":    {runStatus, "status [-n namespace] [-o table|json|yaml]    unhealthy resources reported by the server"},
+	"watch":     {runWatch, "watch [-n namespace] [--interval 2s]           live view of the unhealthy resources and their state changes"},
+	"triage":    {runTriage, "triage [kind/namespace/name]                   root causes of the unhealthy resources"},
+	"explain":   {runExplain, "explain kind/namespace/name                    status, dependencies and history of a resource"},
+	"check":     {runCheck, "check --once [-n namespace] [-l selector]      evaluate the current kubeconfig context once, exit 1 when unhealthy"},
+	"preflight": {runPreflight, "preflight [--timeout 5m] [--report junit|json] wait for the context to converge to healthy, for deploy gates"},
+}
+
+// IsCommand reports whether the argument names a cli subcommand, anything else starts the server
+func IsCommand(arg string) bool {
+	_, ok := commands[arg]
+	return ok || arg == "help" || arg == "-h" || arg == "--help"
+}
+
+// Run executes the subcommand in args[0] and returns the exit code
+func Run(args []string) int {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel) // keep the evaluation logs out of the way
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitError
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(os.Stdout)
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			return exitOK
+		}
+		return exitError
+	}
+	return cmd.run(args[1:], os.Stdout)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: k8sclustervitals [command] [flags], without a command the server starts")
+	fmt.Fprintln(w)
+	for _, name := range []string{"status", "watch", "triage", "explain", "check", "preflight"} {
+		fmt.Fprintln(w, "  "+commands[name].usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "status, watch, triage and explain query the server given by --server or $VITALS_SERVER (default http://localhost:1323)")
+}
+
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	return exitError
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// client queries the http api of a running server
+type client struct {
+	server string
+	http   *http.Client
+}
+
+func serverFlag(flags *flag.FlagSet) *string {
+	server := os.Getenv("VITALS_SERVER")
+	if server == "" {
+		server = "http://localhost:1323"
+	}
+	return flags.String("server", server, "address of the k8sClusterVitals server")
+}
+
+func newClient(server string) *client {
+	return &client{server: strings.TrimSuffix(server, "/"), http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// get decodes the json answer of the path into out, 503 answers carry a body as well
+func (c *client) get(path string, query url.Values, out interface{}) error {
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	resp, err := c.http.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// filterQuery translates a status filter into the query parameters of the api
+func filterQuery(filter helpers.StatusFilter) url.Values {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"cluster":       filter.Cluster,
+		"kind":          filter.Kind,
+		"namespace":     filter.Namespace,
+		"name":          filter.Name,
+		"labelSelector": filter.LabelSelector,
+		"state":         filter.State,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	return query
+}
+
+// filterFlags registers the flags narrowing the resources down
+func filterFlags(flags *flag.FlagSet) *helpers.StatusFilter {
+	filter := &helpers.StatusFilter{}
+	flags.StringVar(&filter.Namespace, "n", "", "namespace")
+	flags.StringVar(&filter.Cluster, "cluster", "", "cluster in multi-cluster mode")
+	flags.StringVar(&filter.Kind, "kind", "", "kind, eg: deployment")
+	flags.StringVar(&filter.LabelSelector, "l", "", "label selector, eg: team=payments")
+	flags.StringVar(&filter.State, "state", "", "state, eg: degraded")
+	return filter
+}
+
+// refFilter parses the kind/namespace/name argument of triage and explain
+func refFilter(args []string) (helpers.StatusFilter, error) {
+	if len(args) == 0 {
+		return helpers.StatusFilter{}, nil
+	}
+	return helpers.ParseResourceRef(args[0])
+}
@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	k8client "github.com/vivekganesan01/k8sClusterVitals/internals"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// splitPositional lets the kind/namespace/name argument come before or after the flags
+func splitPositional(args []string) ([]string, []string) {
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		return args[:1], args[1:]
+	}
+	return nil, args
+}
+
+func runStatus(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	server := serverFlag(flags)
+	filter := filterFlags(flags)
+	output := flags.String("o", "table", "output format: table, json or yaml")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	var records map[string]helpers.StatusRecord
+	if err := newClient(*server).get("/healthcheck/v1/status", filterQuery(*filter), &records); err != nil {
+		return fail(err)
+	}
+	if err := printRecords(stdout, records, *output); err != nil {
+		return fail(err)
+	}
+	if helpers.Unsilenced(records) > 0 {
+		return exitUnhealthy
+	}
+	return exitOK
+}
+
+func runWatch(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	server := serverFlag(flags)
+	filter := filterFlags(flags)
+	interval := flags.Duration("interval", 2*time.Second, "refresh interval")
+	since := flags.Duration("since", time.Hour, "how far back the state changes go")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	c := newClient(*server)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for {
+		var records map[string]helpers.StatusRecord
+		statusErr := c.get("/healthcheck/v1/status", filterQuery(*filter), &records)
+		var transitions []helpers.Transition
+		query := filterQuery(helpers.StatusFilter{Cluster: filter.Cluster, Kind: filter.Kind, Namespace: filter.Namespace})
+		query.Set("since", since.String())
+		historyErr := c.get("/healthcheck/v1/history", query, &transitions)
+
+		fmt.Fprint(stdout, "\033[H\033[2J") // redraw from the top left corner
+		fmt.Fprintf(stdout, "%s  every %s  (ctrl+c to quit)\n\n", time.Now().Format("15:04:05"), *interval)
+		if statusErr != nil {
+			fmt.Fprintln(stdout, "error:", statusErr)
+		} else {
+			printTable(stdout, sortedRecords(records))
+		}
+		fmt.Fprintf(stdout, "\nstate changes in the last %s:\n", *since)
+		if historyErr != nil {
+			fmt.Fprintln(stdout, "error:", historyErr)
+		} else {
+			if len(transitions) > 20 {
+				transitions = transitions[len(transitions)-20:]
+			}
+			printTransitions(stdout, transitions)
+		}
+		select {
+		case <-ctx.Done():
+			return exitOK
+		case <-time.After(*interval):
+		}
+	}
+}
+
+func runTriage(args []string, stdout io.Writer) int {
+	positional, args := splitPositional(args)
+	flags := flag.NewFlagSet("triage", flag.ContinueOnError)
+	server := serverFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	filter, err := refFilter(append(positional, flags.Args()...))
+	if err != nil {
+		return fail(err)
+	}
+	var findings []helpers.TriageFinding
+	if err := newClient(*server).get("/healthcheck/v1/triage", filterQuery(filter), &findings); err != nil {
+		return fail(err)
+	}
+	printTriage(stdout, findings)
+	if len(findings) > 0 {
+		return exitUnhealthy
+	}
+	return exitOK
+}
+
+func runExplain(args []string, stdout io.Writer) int {
+	positional, args := splitPositional(args)
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	server := serverFlag(flags)
+	since := flags.Duration("since", 24*time.Hour, "how far back the state changes go")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	positional = append(positional, flags.Args()...)
+	if len(positional) == 0 {
+		return fail(errors.New("explain needs a kind/namespace/name argument"))
+	}
+	filter, err := refFilter(positional)
+	if err != nil {
+		return fail(err)
+	}
+	c := newClient(*server)
+	var records map[string]helpers.StatusRecord
+	if err := c.get("/healthcheck/v1/status", filterQuery(filter), &records); err != nil {
+		return fail(err)
+	}
+	var findings []helpers.TriageFinding
+	if err := c.get("/healthcheck/v1/triage", filterQuery(filter), &findings); err != nil {
+		return fail(err)
+	}
+	var transitions []helpers.Transition
+	query := filterQuery(filter)
+	query.Set("since", since.String())
+	if err := c.get("/healthcheck/v1/history", query, &transitions); err != nil {
+		return fail(err)
+	}
+
+	if len(records) == 0 {
+		fmt.Fprintf(stdout, "%s is healthy or not monitored\n", positional[0])
+	}
+	for _, record := range sortedRecords(records) {
+		fmt.Fprintf(stdout, "%s is %s since %s", helpers.ResourceRef(record), record.State, record.Since.Local().Format(time.RFC3339))
+		if record.Reason != "" {
+			fmt.Fprintf(stdout, ": %s", record.Reason)
+		}
+		fmt.Fprintln(stdout)
+		if record.Silenced && record.SilencedUntil != nil {
+			fmt.Fprintf(stdout, "  silenced until %s\n", record.SilencedUntil.Local().Format(time.RFC3339))
+		}
+		if len(record.DependsOn) > 0 {
+			fmt.Fprintf(stdout, "  depends on %v\n", record.DependsOn)
+		}
+		if len(record.ImpactedBy) > 0 {
+			fmt.Fprintf(stdout, "  impacted by %v\n", record.ImpactedBy)
+		}
+	}
+	for _, finding := range findings {
+		if len(finding.Impacted) > 0 {
+			fmt.Fprintf(stdout, "  root cause: %s (%s)\n", helpers.ResourceRef(finding.RootCause), finding.RootCause.State)
+		}
+	}
+	fmt.Fprintf(stdout, "\nstate changes in the last %s:\n", *since)
+	printTransitions(stdout, transitions)
+	if helpers.Unsilenced(records) > 0 {
+		return exitUnhealthy
+	}
+	return exitOK
+}
+
+// checkFlags are the flags shared by the commands evaluating the cluster locally
+type checkFlags struct {
+	kubeconfig  *string
+	kubeContext *string
+	namespace   *string
+	selector    *string
+}
+
+func localFlags(flags *flag.FlagSet) checkFlags {
+	return checkFlags{
+		kubeconfig:  flags.String("kubeconfig", "", "path to the kubeconfig, defaults to $KUBECONFIG or ~/.kube/config"),
+		kubeContext: flags.String("context", "", "kubeconfig context, defaults to the current one"),
+		namespace:   flags.String("n", "", "namespace, every namespace when empty"),
+		selector:    flags.String("l", "", "label selector added to "+scrapeLabelSelector),
+	}
+}
+
+// watcher builds a watcher with its own store against the kubeconfig context
+func (f checkFlags) watcher() (*k8client.Watcher, *helpers.KeyValueStore, error) {
+	store := helpers.NewKeyValueStore()
+	watcher, err := k8client.NewLocalKubeClient(store, *f.kubeconfig, *f.kubeContext)
+	if err != nil {
+		return nil, nil, err
+	}
+	watcher.Namespace = *f.namespace
+	return watcher, store, nil
+}
+
+func (f checkFlags) labelSelector() string {
+	if *f.selector == "" {
+		return scrapeLabelSelector
+	}
+	return helpers.LogMsg(scrapeLabelSelector, ",", *f.selector)
+}
+
+// evaluate runs a single evaluation pass against the kubeconfig context and returns the unhealthy resources
+func (f checkFlags) evaluate(ctx context.Context) (map[string]helpers.StatusRecord, error) {
+	watcher, store, err := f.watcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.EvaluateOnce(ctx, f.labelSelector()); err != nil {
+		return nil, err
+	}
+	return store.GetAllStatus(helpers.StatusFilter{}), nil
+}
+
+func runCheck(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	once := flags.Bool("once", true, "run a single evaluation pass and exit, the only supported mode")
+	local := localFlags(flags)
+	output := flags.String("o", "table", "output format: table, json or yaml")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if !*once {
+		return fail(errors.New("check only runs a single evaluation pass, use the server or watch to follow the cluster"))
+	}
+	records, err := local.evaluate(context.Background())
+	if err != nil {
+		return fail(err)
+	}
+	if err := printRecords(stdout, records, *output); err != nil {
+		return fail(err)
+	}
+	if helpers.Unsilenced(records) > 0 {
+		return exitUnhealthy
+	}
+	return exitOK
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	k8client "github.com/vivekganesan01/k8sClusterVitals/internals"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// ansi colors of the states
+const (
+	colorReset  = "\033[0m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorRed    = "\033[31m"
+	colorGrey   = "\033[90m"
+	colorPlain  = "\033[39m" // default foreground, pads the header like the colored cells
+)
+
+// RunKubectlPlugin is the entrypoint of kubectl-vitals: it evaluates the kubeconfig context once with the evaluation
+// of the server and prints every monitored resource, healthy or not
+func RunKubectlPlugin(args []string) int {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	flags := flag.NewFlagSet("kubectl vitals", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: kubectl vitals [-n namespace | -A] [-l selector] [--context name]")
+		flags.PrintDefaults()
+	}
+	local := localFlags(flags)
+	flags.StringVar(local.namespace, "namespace", "", "namespace, defaults to the namespace of the context")
+	allNamespaces := flags.Bool("A", false, "every namespace")
+	flags.BoolVar(allNamespaces, "all-namespaces", false, "every namespace")
+	flags.StringVar(local.selector, "selector", "", "label selector added to "+scrapeLabelSelector)
+	output := flags.String("o", "table", "output format: table, json or yaml")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitError
+	}
+	if *allNamespaces {
+		*local.namespace = ""
+	} else if *local.namespace == "" {
+		*local.namespace = k8client.KubeconfigNamespace(*local.kubeconfig, *local.kubeContext)
+	}
+	watcher, store, err := local.watcher()
+	if err != nil {
+		return fail(err)
+	}
+	if err := watcher.EvaluateOnce(context.Background(), local.labelSelector()); err != nil {
+		return fail(err)
+	}
+	unhealthy := store.GetAllStatus(helpers.StatusFilter{})
+	if *output != "table" {
+		if err := printRecords(os.Stdout, unhealthy, *output); err != nil {
+			return fail(err)
+		}
+	} else {
+		printInventory(os.Stdout, store.Inventory(helpers.StatusFilter{}), unhealthy, useColor(os.Stdout))
+	}
+	if helpers.Unsilenced(unhealthy) > 0 {
+		return exitUnhealthy
+	}
+	return exitOK
+}
+
+// useColor is true on a terminal unless NO_COLOR is set
+func useColor(f *os.File) bool {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return !noColor && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
+// printInventory writes a row per evaluated resource, healthy ones included
+func printInventory(w io.Writer, inventory []helpers.InventoryEntry, unhealthy map[string]helpers.StatusRecord, color bool) {
+	if len(inventory) == 0 {
+		fmt.Fprintln(w, "no monitored resources found, label workloads with "+scrapeLabelSelector)
+		return
+	}
+	records := make(map[string]helpers.StatusRecord, len(inventory))
+	for _, entry := range inventory {
+		record, ok := unhealthy[entry.Key]
+		if !ok {
+			record = helpers.StatusRecord{Kind: entry.Kind, Namespace: entry.Namespace, Name: entry.Name, State: helpers.StateHealthy}
+		}
+		records[entry.Key] = record
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := "STATE"
+	if color {
+		// tabwriter counts the escape codes as width, every cell of the column carries codes of the same length
+		header = helpers.LogMsg(colorPlain, header, colorReset)
+	}
+	fmt.Fprintf(tw, "NAMESPACE\tKIND\tNAME\t%s\tREASON\n", header)
+	for _, record := range sortedRecords(records) {
+		state := record.State
+		if record.Silenced {
+			state += " (silenced)"
+		}
+		if color {
+			state = helpers.LogMsg(stateColor(record), state, colorReset)
+		}
+		reason := record.Reason
+		if len(record.ImpactedBy) > 0 {
+			reason = helpers.LogMsg(reason, " impacted by ", fmt.Sprint(record.ImpactedBy))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", record.Namespace, record.Kind, record.Name, state, reason)
+	}
+	tw.Flush()
+}
+
+func stateColor(record helpers.StatusRecord) string {
+	switch {
+	case record.Silenced:
+		return colorGrey
+	case record.State == helpers.StateHealthy:
+		return colorGreen
+	case record.State == helpers.StateDegraded:
+		return colorYellow
+	}
+	return colorRed
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+	"sigs.k8s.io/yaml"
+)
+
+// sortedRecords orders the records by cluster, namespace, kind and name
+func sortedRecords(records map[string]helpers.StatusRecord) []helpers.StatusRecord {
+	sorted := make([]helpers.StatusRecord, 0, len(records))
+	for _, record := range records {
+		sorted = append(sorted, record)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return sorted
+}
+
+// printRecords writes the records as a table, json or yaml
+func printRecords(w io.Writer, records map[string]helpers.StatusRecord, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(sortedRecords(records))
+	case "yaml":
+		data, err := yaml.Marshal(sortedRecords(records))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case "table", "":
+		printTable(w, sortedRecords(records))
+		return nil
+	}
+	return fmt.Errorf("unknown output format %s, expected table, json or yaml", format)
+}
+
+func printTable(w io.Writer, records []helpers.StatusRecord) {
+	if len(records) == 0 {
+		fmt.Fprintln(w, "all monitored resources are healthy")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tKIND\tNAME\tSTATE\tSINCE\tREASON")
+	for _, record := range records {
+		state := record.State
+		if record.Silenced {
+			state += " (silenced)"
+		}
+		reason := record.Reason
+		if len(record.ImpactedBy) > 0 {
+			reason = helpers.LogMsg(reason, " impacted by ", fmt.Sprint(record.ImpactedBy))
+		}
+		namespace := record.Namespace
+		if record.Cluster != "" {
+			namespace = helpers.LogMsg(record.Cluster, "/", namespace)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", namespace, record.Kind, record.Name, state, age(record.Since), reason)
+	}
+	tw.Flush()
+}
+
+// age renders how long ago t was, eg: 3m
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+func printTransitions(w io.Writer, transitions []helpers.Transition) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tNAMESPACE\tKIND\tNAME\tTRANSITION\tREASON")
+	for _, t := range transitions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s -> %s\t%s\n", t.Timestamp.Local().Format("15:04:05"), t.Namespace, t.Kind, t.Name, t.From, t.To, t.Reason)
+	}
+	tw.Flush()
+}
+
+func printTriage(w io.Writer, findings []helpers.TriageFinding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "nothing to triage, all monitored resources are healthy")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROOT CAUSE\tSTATE\tREASON\tIMPACTS")
+	for _, finding := range findings {
+		impacts := "-"
+		if len(finding.Impacted) > 0 {
+			impacts = fmt.Sprint(finding.Impacted)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", helpers.ResourceRef(finding.RootCause), finding.RootCause.State, finding.RootCause.Reason, impacts)
+	}
+	tw.Flush()
+}
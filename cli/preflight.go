@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// preflightReport is the json report of a preflight run
+type preflightReport struct {
+	Status     string              `json:"status"` // passed, failed or error
+	Error      string              `json:"error,omitempty"`
+	StartedAt  time.Time           `json:"startedAt"`
+	FinishedAt time.Time           `json:"finishedAt"`
+	Attempts   int                 `json:"attempts"`
+	Resources  []preflightResource `json:"resources"`
+}
+
+type preflightResource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// junit xml understood by jenkins, gitlab and most ci systems
+type junitTestSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+}
+
+// runPreflight evaluates the context until everything is healthy or the timeout expires, without the watch loops
+// or the http server, and writes a junit or json report
+func runPreflight(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("preflight", flag.ContinueOnError)
+	local := localFlags(flags)
+	timeout := flags.Duration("timeout", 5*time.Minute, "how long to wait for every resource to be healthy")
+	interval := flags.Duration("interval", 10*time.Second, "delay between evaluations")
+	format := flags.String("report", "json", "report format: junit or json")
+	reportFile := flags.String("report-file", "", "write the report to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *format != "junit" && *format != "json" {
+		return fail(fmt.Errorf("unknown report format %s, expected junit or json", *format))
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	report := preflightReport{StartedAt: time.Now()}
+	var store *helpers.KeyValueStore // of the last complete evaluation
+	var lastErr error
+	for {
+		report.Attempts++
+		// a fresh store per attempt, resources fixed or deleted since the previous one do not linger
+		watcher, attempt, err := local.watcher()
+		if err != nil {
+			return fail(err)
+		}
+		// the timeout cuts the api calls in flight short, an interrupted attempt does not replace a complete one
+		lastErr = watcher.EvaluateOnce(ctx, local.labelSelector())
+		if lastErr == nil || store == nil {
+			store = attempt
+		}
+		if lastErr == nil && helpers.Unsilenced(store.GetAllStatus(helpers.StatusFilter{})) == 0 {
+			break
+		}
+		if !sleep(ctx, *interval) {
+			break
+		}
+	}
+	report.FinishedAt = time.Now()
+	unhealthy := store.GetAllStatus(helpers.StatusFilter{})
+	for _, entry := range store.Inventory(helpers.StatusFilter{}) {
+		resource := preflightResource{Kind: entry.Kind, Namespace: entry.Namespace, Name: entry.Name, State: helpers.StateHealthy}
+		if record, ok := unhealthy[entry.Key]; ok && !record.Silenced {
+			resource.State, resource.Reason = record.State, record.Reason
+		}
+		report.Resources = append(report.Resources, resource)
+	}
+	code := exitOK
+	report.Status = "passed"
+	if helpers.Unsilenced(unhealthy) > 0 {
+		code, report.Status = exitUnhealthy, "failed"
+	} else if lastErr != nil {
+		code, report.Status, report.Error = exitError, "error", lastErr.Error()
+	}
+
+	out := stdout
+	if *reportFile != "" {
+		file, err := os.Create(*reportFile)
+		if err != nil {
+			return fail(err)
+		}
+		defer file.Close()
+		out = file
+	}
+	var err error
+	if *format == "junit" {
+		err = writeJUnit(out, report)
+	} else {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Fprintf(os.Stderr, "preflight %s after %d evaluation(s) in %s\n", report.Status, report.Attempts, report.FinishedAt.Sub(report.StartedAt).Round(time.Second))
+	return code
+}
+
+func writeJUnit(w io.Writer, report preflightReport) error {
+	suite := junitSuite{
+		Name:      "k8sclustervitals",
+		Tests:     len(report.Resources),
+		Time:      fmt.Sprintf("%.3f", report.FinishedAt.Sub(report.StartedAt).Seconds()),
+		Timestamp: report.StartedAt.UTC().Format(time.RFC3339),
+	}
+	for _, resource := range report.Resources {
+		testCase := junitTestCase{ClassName: helpers.LogMsg(resource.Kind, ".", resource.Namespace), Name: resource.Name}
+		if resource.State != helpers.StateHealthy {
+			testCase.Failure = &junitFailure{Type: resource.State, Message: resource.Reason}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	if report.Error != "" {
+		suite.Errors++
+		suite.Tests++
+		suite.Cases = append(suite.Cases, junitTestCase{ClassName: "k8sclustervitals", Name: "evaluation", Error: &junitFailure{Type: "error", Message: report.Error}})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// sleep waits for the interval, false when the context ends first
+func sleep(ctx context.Context, interval time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(interval):
+		return true
+	}
+}
@@ -0,0 +1,12 @@
+// kubectl-vitals is the kubectl plugin, installed on the PATH it runs as `kubectl vitals`
+package main
+
+import (
+	"os"
+
+	"github.com/vivekganesan01/k8sClusterVitals/cli"
+)
+
+func main() {
+	os.Exit(cli.RunKubectlPlugin(os.Args[1:]))
+}
@@ -0,0 +1,22 @@
+// Package dashboard serves the single page dashboard embedded in the binary
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+//go:embed static
+var static embed.FS
+
+// Register serves the dashboard under /dashboard/, it only reads the json api of the server
+func Register(e *echo.Echo) {
+	assets, _ := fs.Sub(static, "static") // cannot fail, static is embedded
+	e.GET("/dashboard", func(c echo.Context) error {
+		return c.Redirect(http.StatusMovedPermanently, "/dashboard/")
+	})
+	e.GET("/dashboard/*", echo.WrapHandler(http.StripPrefix("/dashboard/", http.FileServer(http.FS(assets)))))
+}
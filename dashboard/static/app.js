@@ -0,0 +1,195 @@
+// k8sClusterVitals dashboard, renders the json api of the server. no build step, no dependencies.
+(function () {
+  "use strict";
+
+  const api = "/healthcheck/v1";
+  const day = 24 * 60 * 60 * 1000;
+  const state = { inventory: [], status: {}, history: [], triage: [], triageFilter: null, search: "" };
+
+  const escape = (v) => String(v == null ? "" : v).replace(/[&<>"']/g, (c) => ({ "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;" }[c]));
+
+  // with authentication enabled on the server the dashboard asks once for a bearer token or api key, kept for the tab only.
+  // client certificates are sent by the browser
+  const tokenKey = "k8sclustervitals.token";
+  const authHeaders = () => {
+    const token = sessionStorage.getItem(tokenKey);
+    return token ? { Authorization: "Bearer " + token } : {};
+  };
+
+  async function request(url) {
+    const sent = sessionStorage.getItem(tokenKey);
+    const response = await fetch(url, { headers: authHeaders() });
+    if (response.status !== 401) return response;
+    // concurrent requests share the token entered for the first of them
+    if (sessionStorage.getItem(tokenKey) === sent) {
+      const token = window.prompt("k8sClusterVitals requires authentication, paste a bearer token or api key");
+      if (!token) return response;
+      sessionStorage.setItem(tokenKey, token.trim());
+    }
+    return fetch(url, { headers: authHeaders() });
+  }
+
+  async function json(path) {
+    const response = await request(api + path);
+    if (!response.ok && response.status !== 503) throw new Error(path + ": " + response.status);
+    return response.json();
+  }
+
+  async function text(path) {
+    const response = await request(api + path);
+    return response.text();
+  }
+
+  // every monitored resource, healthy ones come from the inventory only
+  function resources() {
+    const rows = {};
+    for (const entry of state.inventory || []) {
+      rows[entry.key] = { key: entry.key, cluster: entry.cluster, kind: entry.kind, namespace: entry.namespace, name: entry.name, state: "healthy", replicas: entry.replicas, since: entry.firstSeen };
+    }
+    for (const [key, record] of Object.entries(state.status || {})) {
+      rows[key] = Object.assign({ key: key }, rows[key] || {}, record);
+      if (record.silenced) rows[key].badge = "silenced";
+    }
+    const transitions = {};
+    for (const t of state.history || []) (transitions[t.key] = transitions[t.key] || []).push(t);
+    for (const row of Object.values(rows)) {
+      row.transitions = transitions[row.key] || [];
+      const last = row.transitions[row.transitions.length - 1];
+      row.lastTransition = last ? last.timestamp : null;
+    }
+    const search = state.search.toLowerCase();
+    return Object.values(rows).filter((row) => !search || [row.cluster, row.namespace, row.kind, row.name].join(" ").toLowerCase().includes(search));
+  }
+
+  // sparkline of the last 24 hours, one segment per state
+  function sparkline(row) {
+    const width = 160, height = 14, now = Date.now(), start = now - day;
+    const transitions = row.transitions.filter((t) => Date.parse(t.timestamp) >= start);
+    let current = transitions.length ? transitions[0].from : row.state;
+    let from = start;
+    let segments = "";
+    const segment = (until, s) => {
+      const x = ((from - start) / day) * width, w = Math.max(((until - from) / day) * width, 1);
+      segments += `<rect x="${x.toFixed(1)}" y="0" width="${w.toFixed(1)}" height="${height}" class="${escape(s)}"><title>${escape(s)}</title></rect>`;
+    };
+    for (const t of transitions) {
+      const at = Date.parse(t.timestamp);
+      segment(at, current);
+      from = at;
+      current = t.to;
+    }
+    segment(now, current);
+    return `<svg class="sparkline" width="${width}" height="${height}" viewBox="0 0 ${width} ${height}">${segments.replace(/class="([^"]+)"/g, (_, s) => `fill="var(--${color(s)})"`)}</svg>`;
+  }
+
+  function color(s) {
+    if (s === "healthy") return "healthy";
+    if (s === "degraded" || s === "flapping") return "degraded";
+    return "unhealthy";
+  }
+
+  const ago = (t) => {
+    if (!t) return "-";
+    const s = Math.round((Date.now() - Date.parse(t)) / 1000);
+    if (s < 60) return s + "s ago";
+    if (s < 3600) return Math.round(s / 60) + "m ago";
+    if (s < 2 * 86400) return Math.round(s / 3600) + "h ago";
+    return Math.round(s / 86400) + "d ago";
+  };
+
+  function renderResources() {
+    const byNamespace = {};
+    for (const row of resources()) {
+      const namespace = (row.cluster ? row.cluster + "/" : "") + row.namespace;
+      ((byNamespace[namespace] = byNamespace[namespace] || {})[row.kind] = byNamespace[namespace][row.kind] || []).push(row);
+    }
+    let html = "";
+    for (const namespace of Object.keys(byNamespace).sort()) {
+      html += `<h2>${escape(namespace)}</h2>`;
+      for (const kind of Object.keys(byNamespace[namespace]).sort()) {
+        html += `<h3>${escape(kind)}</h3><table><thead><tr><th>Name</th><th>State</th><th>Replicas</th><th>Last transition</th><th>Last 24h</th><th>Reason</th></tr></thead><tbody>`;
+        for (const row of byNamespace[namespace][kind].sort((a, b) => a.name.localeCompare(b.name))) {
+          const replicas = row.replicas ? `${row.replicas.ready}/${row.replicas.desired}` : "-";
+          const drill = row.state !== "healthy" ? ` <a class="drill" data-kind="${escape(row.kind)}" data-namespace="${escape(row.namespace)}" data-name="${escape(row.name)}">triage</a>` : "";
+          const impacted = row.impactedBy && row.impactedBy.length ? ` impacted by ${escape(row.impactedBy.join(", "))}` : "";
+          html += `<tr><td>${escape(row.name)}</td><td><span class="badge ${escape(row.badge || row.state)}">${escape(row.state)}${row.silenced ? " (silenced)" : ""}</span></td>` +
+            `<td>${replicas}</td><td title="${escape(row.lastTransition || "")}">${ago(row.lastTransition)}</td><td>${sparkline(row)}</td>` +
+            `<td class="reason">${escape(row.reason)}${impacted}${drill}</td></tr>`;
+        }
+        html += "</tbody></table>";
+      }
+    }
+    document.getElementById("resources").innerHTML = html || "<p>No monitored resources yet, label workloads with <code>k8sclustervitals.io/scrape=true</code>.</p>";
+  }
+
+  function renderTriage() {
+    const filter = state.triageFilter;
+    let html = filter ? `<p>Root causes of <strong>${escape(filter.kind + "/" + filter.namespace + "/" + filter.name)}</strong> <a class="drill" id="triage-all">show all</a></p>` : "";
+    if (!state.triage.length) {
+      html += "<p>Nothing to triage, every monitored resource is healthy.</p>";
+    } else {
+      html += "<table><thead><tr><th>Root cause</th><th>State</th><th>Reason</th><th>Impacts</th></tr></thead><tbody>";
+      for (const finding of state.triage) {
+        const root = finding.rootCause;
+        html += `<tr><td>${escape(root.kind + "/" + root.namespace + "/" + root.name)}</td><td><span class="badge ${escape(root.state)}">${escape(root.state)}</span></td>` +
+          `<td class="reason">${escape(root.reason)}</td><td>${escape((finding.impacted || []).join(", ") || "-")}</td></tr>`;
+      }
+      html += "</tbody></table>";
+    }
+    document.getElementById("triage").innerHTML = html;
+    const all = document.getElementById("triage-all");
+    if (all) all.onclick = () => { state.triageFilter = null; refreshTriage(); };
+  }
+
+  async function refreshTriage() {
+    const f = state.triageFilter;
+    const query = f ? `?kind=${encodeURIComponent(f.kind)}&namespace=${encodeURIComponent(f.namespace)}&name=${encodeURIComponent(f.name)}` : "";
+    state.triage = (await json("/triage" + query)) || [];
+    renderTriage();
+  }
+
+  async function refresh() {
+    try {
+      const [inventory, status, history, verdict, configuration] = await Promise.all([
+        json("/inventory"), json("/status"), json("/history?since=24h"), text("/health"), request("/healthcheck/v2/scrape_configuration").then((r) => r.json()),
+      ]);
+      Object.assign(state, { inventory: inventory, status: status, history: history });
+      const badge = document.getElementById("verdict");
+      badge.textContent = verdict;
+      badge.className = "badge " + verdict;
+      document.getElementById("configuration-body").textContent = JSON.stringify(configuration.items, null, 2);
+      renderResources();
+      await refreshTriage();
+      document.getElementById("updated").textContent = "updated " + new Date().toLocaleTimeString();
+    } catch (err) {
+      document.getElementById("updated").textContent = "refresh failed: " + err.message;
+    }
+  }
+
+  function show(tab) {
+    for (const section of document.querySelectorAll("main > section")) section.hidden = "#" + section.id !== tab;
+    for (const link of document.querySelectorAll("nav a")) link.classList.toggle("active", link.getAttribute("href") === tab);
+  }
+
+  document.getElementById("search").addEventListener("input", (e) => { state.search = e.target.value; renderResources(); });
+  document.getElementById("resources").addEventListener("click", (e) => {
+    const link = e.target.closest("a.drill");
+    if (!link) return;
+    state.triageFilter = { kind: link.dataset.kind, namespace: link.dataset.namespace, name: link.dataset.name };
+    location.hash = "#triage";
+    refreshTriage();
+  });
+  window.addEventListener("hashchange", () => show(location.hash || "#resources"));
+  show(location.hash || "#resources");
+
+  // refresh on every state change, the interval catches up when the stream is unavailable. EventSource cannot send
+  // an authorization header, with a token the dashboard polls
+  let pending = null;
+  const schedule = () => { if (!pending) pending = setTimeout(() => { pending = null; refresh(); }, 1000); };
+  if (window.EventSource) {
+    const stream = new EventSource(api + "/stream");
+    stream.addEventListener("change", schedule);
+  }
+  setInterval(refresh, 30000);
+  refresh();
+})();
@@ -5,4 +5,67 @@ export KUBE_HOME=${HOME}
 export ENV="kubeconfig"
 
 # via k8 cluster deployment
-# export ENV="inclusterconfig"
\ No newline at end of file
+# export ENV="inclusterconfig"
+
+# optional: how long an hpa may sit at maxReplicas before the workload is degraded
+# export HPA_SATURATION_WINDOW="5m"
+# optional: how long a pdb may allow zero disruptions before it is reported
+# export PDB_BLOCKED_WINDOW="15m"
+
+# optional: multi-cluster mode, one watcher per kubeconfig context or per secret (namespace/name) holding a kubeconfig key
+# export CLUSTER_NAME="hub"
+# export KUBE_CONTEXTS="prod-eu,prod-us"
+# export KUBECONFIG_SECRETS="k8cv/prod-eu,k8cv/prod-us"
+
+# optional: lease based leader election, only the leader watches resources
+# export LEADER_ELECTION="true"
+# export LEADER_ELECTION_LEASE="k8sclustervitals"
+# export POD_NAMESPACE="k8cv"
+
+# optional: persist status across restarts, backend is bolt or configmap
+# export PERSISTENCE_BACKEND="bolt"
+# export PERSISTENCE_PATH="/var/lib/k8sclustervitals/vitals.db"
+# export PERSISTENCE_CONFIGMAP="k8sclustervitals-state"
+
+# optional: track every secret and configmap referenced by the pod template of scraped workloads
+# export AUTO_DISCOVER_REFERENCES="true"
+
+# optional: number of state transitions kept per resource
+# export HISTORY_SIZE="100"
+
+# optional: state changes kept for stream subscribers resuming with a last event id
+# export EVENT_LOG_SIZE="1000"
+
+# optional: validates every json response of the v2 api against /openapi.json and logs the violations, for staging and ci
+# export API_CONTRACT_CHECK="true"
+
+# optional: https for the http and grpc servers, the files are reloaded when they change
+# export TLS_CERT_FILE="/etc/k8sclustervitals/tls/tls.crt"
+# export TLS_KEY_FILE="/etc/k8sclustervitals/tls/tls.key"
+# export TLS_CLIENT_CA_FILE="/etc/k8sclustervitals/tls/ca.crt"
+# export TLS_CLIENT_AUTH="verify-if-given"
+# export TLS_MIN_VERSION="1.2"
+# export TLS_CIPHER_SUITES="TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"
+
+# optional: kubernetes events on health transitions are recorded unless disabled
+# export RECORD_EVENTS="false"
+
+# optional: authentication of the http api, probes and health verdicts stay open
+# export AUTH_TOKEN_REVIEW="true"
+# export AUTH_API_KEYS_FILE="/etc/k8sclustervitals/auth/api-keys.csv"
+# export AUTH_MTLS="true"
+# export AUTH_SUBJECT_ACCESS_REVIEW="true"
+# export AUTH_OPEN_PATHS="/readiness,/healthcheck/v1/health"
+
+# optional: port of the grpc server (grpc.health.v1 and the Vitals service)
+# export GRPC_PORT="9090"
+
+# optional: rolling windows of the availability report
+# export SLO_WINDOWS="1h,24h,720h"
+
+# optional: hysteresis, consecutive evaluations (every 15s) before a resource is reported or cleared
+# export HEALTH_FAILURE_THRESHOLD="3"
+# export HEALTH_SUCCESS_THRESHOLD="2"
+# optional: flap detection, state changes within the window which hold the resource as flapping
+# export FLAP_THRESHOLD="4"
+# export FLAP_WINDOW="10m"
\ No newline at end of file
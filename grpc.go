@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	vitalsv1 "github.com/vivekganesan01/k8sClusterVitals/api/vitals/v1"
+	k8client "github.com/vivekganesan01/k8sClusterVitals/internals"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcEnabled is true with GRPC_ENABLED=true, the grpc server is off by default
+func grpcEnabled() bool {
+	return os.Getenv("GRPC_ENABLED") == "true"
+}
+
+// grpcReflectionEnabled is true with GRPC_REFLECTION=true, reflection lists every service to anyone who asks
+func grpcReflectionEnabled() bool {
+	return os.Getenv("GRPC_REFLECTION") == "true"
+}
+
+// grpcAddress is the listen address of the grpc server, GRPC_PORT defaults to 9090
+func grpcAddress() string {
+	if port := os.Getenv("GRPC_PORT"); port != "" {
+		return ":" + port
+	}
+	return ":9090"
+}
+
+// grpcServer serves grpc.health.v1.Health and the Vitals service next to the http server, over tls when certs is set
+func grpcServer(ctx context.Context, certs *certReloader) {
+	listener, err := net.Listen("tcp", grpcAddress())
+	if err != nil {
+		log.Error().Str("caller", "grpc.go").Msg(helpers.LogMsg("failed to listen for grpc: ", err.Error()))
+		return
+	}
+	var options []grpc.ServerOption
+	if certs != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(certs.config("h2"))))
+	}
+	if auth != nil {
+		options = append(options, grpc.UnaryInterceptor(auth.unaryInterceptor), grpc.StreamInterceptor(auth.streamInterceptor))
+	}
+	server := grpc.NewServer(options...)
+	healthpb.RegisterHealthServer(server, healthService{})
+	vitalsv1.RegisterVitalsServer(server, vitalsService{})
+	if grpcReflectionEnabled() {
+		reflection.Register(server) // grpcurl and friends list the services without the proto files
+	}
+	go func() {
+		log.Info().Msg(helpers.LogMsg("Starting gRPC server on ", grpcAddress(), "..."))
+		if err := server.Serve(listener); err != nil {
+			log.Error().Str("caller", "grpc.go").Msg(helpers.LogMsg("grpc server stopped: ", err.Error()))
+		}
+	}()
+	<-ctx.Done()
+	log.Info().Msg("Shutting down gRPC server...")
+	server.GracefulStop()
+}
+
+// grpc.health.v1 stays open like the http health verdicts, kubelet grpc probes do not authenticate. credentials are
+// still read when given, resource checks are only answered for authenticated callers
+const grpcHealthPrefix = "/grpc.health.v1.Health/"
+
+// authenticateHealth authenticates the health calls which carry credentials, the others go on anonymously
+func (a *authConfig) authenticateHealth(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if len(md.Get("x-api-key")) == 0 && len(md.Get("authorization")) == 0 && !a.mtls {
+		return ctx
+	}
+	authenticated, err := a.authenticateGRPC(ctx)
+	if err != nil {
+		return ctx
+	}
+	return authenticated
+}
+
+type grpcIdentityKey struct{}
+
+// authenticateGRPC authenticates the caller like the http middleware, the x-api-key and authorization metadata
+// carry the api key or bearer token and the peer certificate is used with AUTH_MTLS
+func (a *authConfig) authenticateGRPC(ctx context.Context) (context.Context, error) {
+	var state *tls.ConnectionState
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state = &info.State
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	identity, err := a.authenticateCredentials(ctx, state, first("x-api-key"), first("authorization"))
+	if err != nil {
+		if errors.Is(err, errUnauthenticated) || errors.Is(err, k8client.ErrTokenRejected) {
+			return ctx, grpcstatus.Error(codes.Unauthenticated, "valid credentials are required")
+		}
+		log.Error().Str("caller", "grpc.go").Msg(helpers.LogMsg("failed to review token: ", err.Error()))
+		return ctx, grpcstatus.Error(codes.Unavailable, "unable to review the credentials")
+	}
+	return context.WithValue(ctx, grpcIdentityKey{}, identity), nil
+}
+
+func (a *authConfig) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if strings.HasPrefix(info.FullMethod, grpcHealthPrefix) {
+		return handler(a.authenticateHealth(ctx), req)
+	}
+	ctx, err := a.authenticateGRPC(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authConfig) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if strings.HasPrefix(info.FullMethod, grpcHealthPrefix) {
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: a.authenticateHealth(stream.Context())})
+	}
+	ctx, err := a.authenticateGRPC(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticatedStream carries the identity of the caller in its context
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcAuthorizer narrows the filters of the call down to the namespaces its caller can get deployments in, nil when
+// every namespace is visible
+func grpcAuthorizer(ctx context.Context) func(namespace string) bool {
+	if auth == nil || !auth.authorize {
+		return nil
+	}
+	identity, ok := ctx.Value(grpcIdentityKey{}).(k8client.Identity)
+	if !ok {
+		return nil
+	}
+	return identityAuthorizer(ctx, identity)
+}
+
+// healthService implements grpc.health.v1.Health, the service name selects what is checked:
+//
+//	""                            every monitored resource, like /healthcheck/v1/health
+//	namespace/<namespace>         the resources of a namespace
+//	group/<name>                  a health group
+//	<kind>/<name>                 a resource, eg: deployment/payments/api or statefulset/redis
+//	<kind>/<namespace>/<name>
+//
+// with authentication enabled anonymous callers get SERVICE_UNKNOWN for every resource, whether it exists or not
+type healthService struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (healthService) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	serving, known := servingStatus(ctx, req.GetService())
+	if !known {
+		return nil, grpcstatus.Error(codes.NotFound, helpers.LogMsg("unknown service ", req.GetService()))
+	}
+	return &healthpb.HealthCheckResponse{Status: serving}, nil
+}
+
+// Watch sends the serving status of the service then every change of it, state changes and the keepalive
+// interval trigger a new evaluation so that the end of the warm up and configuration changes are noticed
+func (healthService) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	events, _, _, cancel := cacheStore.Subscribe(0)
+	defer func() { cancel() }()
+	ticker := time.NewTicker(streamKeepalive)
+	defer ticker.Stop()
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		serving, known := servingStatus(stream.Context(), req.GetService())
+		if !known {
+			serving = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+		if serving != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: serving}); err != nil {
+				return err
+			}
+			last = serving
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case _, ok := <-events:
+			if !ok {
+				// fell behind, the status is evaluated again anyway
+				cancel()
+				events, _, _, cancel = cacheStore.Subscribe(0)
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// servingStatus evaluates the health service name, known is false when it does not match anything monitored
+func servingStatus(ctx context.Context, service string) (serving healthpb.HealthCheckResponse_ServingStatus, known bool) {
+	verdict := func(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+		// not a verdict yet while the first full evaluation has not completed
+		if ok && !k8client.WarmingUp() {
+			return healthpb.HealthCheckResponse_SERVING
+		}
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	switch {
+	case service == "":
+		return verdict(helpers.Unsilenced(cacheStore.GetAllStatus(helpers.StatusFilter{})) == 0), true
+	case strings.HasPrefix(service, "namespace/"):
+		filter := helpers.StatusFilter{Namespace: strings.TrimPrefix(service, "namespace/")}
+		return verdict(helpers.Unsilenced(cacheStore.GetAllStatus(filter)) == 0), true
+	case strings.HasPrefix(service, "group/"):
+		for _, group := range k8client.HealthGroups("") {
+			if group.Name == strings.TrimPrefix(service, "group/") {
+				return verdict(cacheStore.GroupHealth(group).Status == "ok"), true
+			}
+		}
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+	if _, ok := ctx.Value(grpcIdentityKey{}).(k8client.Identity); auth != nil && !ok {
+		// NotFound would tell anonymous callers which resources exist
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, true
+	}
+	filter, err := helpers.ParseResourceRef(service)
+	if err != nil {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+	filter.Authorized = grpcAuthorizer(ctx)
+	if len(cacheStore.Inventory(filter)) == 0 {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+	return verdict(helpers.Unsilenced(cacheStore.GetAllStatus(filter)) == 0), true
+}
+
+// vitalsService implements the Vitals service of api/vitals/v1/vitals.proto on top of the store
+type vitalsService struct {
+	vitalsv1.UnimplementedVitalsServer
+}
+
+func (vitalsService) List(ctx context.Context, req *vitalsv1.ListRequest) (*vitalsv1.ListResponse, error) {
+	filter, err := protoFilter(ctx, req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	return &vitalsv1.ListResponse{Records: protoRecords(cacheStore.GetAllStatus(filter))}, nil
+}
+
+func (vitalsService) Get(ctx context.Context, req *vitalsv1.GetRequest) (*vitalsv1.GetResponse, error) {
+	filter, err := helpers.ParseResourceRef(req.GetResource())
+	if err != nil {
+		return nil, grpcstatus.Error(codes.InvalidArgument, err.Error())
+	}
+	filter.Cluster = req.GetCluster()
+	filter.Authorized = grpcAuthorizer(ctx)
+	entries := cacheStore.Inventory(filter)
+	switch {
+	case len(entries) == 0:
+		return nil, grpcstatus.Error(codes.NotFound, helpers.LogMsg(req.GetResource(), " is not monitored"))
+	case len(entries) > 1:
+		return nil, grpcstatus.Error(codes.InvalidArgument, helpers.LogMsg(req.GetResource(), " matches several resources, use kind/namespace/name and the cluster"))
+	}
+	entry := entries[0]
+	if record, ok := cacheStore.GetAllStatus(filter)[entry.Key]; ok {
+		return &vitalsv1.GetResponse{Record: protoRecord(entry.Key, record)}, nil
+	}
+	// healthy resources have no status record, the inventory knows them
+	healthy := helpers.StatusRecord{Cluster: entry.Cluster, Kind: entry.Kind, Namespace: entry.Namespace, Name: entry.Name, State: helpers.StateHealthy, SLOTarget: entry.SLOTarget, Since: entry.FirstSeen, CheckedAt: entry.LastSeen, Labels: entry.Labels, Replicas: entry.Replicas}
+	return &vitalsv1.GetResponse{Record: protoRecord(entry.Key, healthy)}, nil
+}
+
+func (vitalsService) Watch(req *vitalsv1.WatchRequest, stream vitalsv1.Vitals_WatchServer) error {
+	filter, err := protoFilter(stream.Context(), req.GetFilter())
+	if err != nil {
+		return err
+	}
+	streamMessages(stream.Context(), filter, req.GetLastEventId(), func(message streamMessage) error {
+		event := &vitalsv1.WatchEvent{Type: message.Type, Id: message.ID, Records: protoRecords(message.Records)}
+		if t := message.Transition; t != nil {
+			event.Transition = &vitalsv1.Transition{Key: t.Key, Cluster: t.Cluster, Kind: t.Kind, Namespace: t.Namespace, Name: t.Name, Labels: t.Labels, From: t.From, To: t.To, Reason: t.Reason, Timestamp: timestamppb.New(t.Timestamp)}
+		}
+		return stream.Send(event)
+	}, nil) // grpc has its own keepalive
+	return nil
+}
+
+func (vitalsService) Triage(ctx context.Context, req *vitalsv1.TriageRequest) (*vitalsv1.TriageResponse, error) {
+	filter, err := protoFilter(ctx, req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	response := &vitalsv1.TriageResponse{}
+	for _, finding := range cacheStore.Triage(filter) {
+		response.Findings = append(response.Findings, &vitalsv1.TriageFinding{Key: finding.Key, RootCause: protoRecord(finding.Key, finding.RootCause), Impacted: finding.Impacted})
+	}
+	return response, nil
+}
+
+// protoFilter converts the filter of a call, limited to the namespaces visible to its caller
+func protoFilter(ctx context.Context, f *vitalsv1.StatusFilter) (helpers.StatusFilter, error) {
+	filter := helpers.StatusFilter{
+		Cluster:       f.GetCluster(),
+		Kind:          f.GetKind(),
+		Namespace:     f.GetNamespace(),
+		Name:          f.GetName(),
+		LabelSelector: f.GetLabelSelector(),
+		State:         f.GetState(),
+		Authorized:    grpcAuthorizer(ctx),
+	}
+	if err := filter.Validate(); err != nil {
+		return filter, grpcstatus.Error(codes.InvalidArgument, helpers.LogMsg("invalid labelSelector: ", err.Error()))
+	}
+	return filter, nil
+}
+
+// protoRecords converts the records sorted by key
+func protoRecords(records map[string]helpers.StatusRecord) []*vitalsv1.StatusRecord {
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	converted := make([]*vitalsv1.StatusRecord, 0, len(keys))
+	for _, key := range keys {
+		converted = append(converted, protoRecord(key, records[key]))
+	}
+	return converted
+}
+
+func protoRecord(key string, record helpers.StatusRecord) *vitalsv1.StatusRecord {
+	converted := &vitalsv1.StatusRecord{
+		Key:        key,
+		Cluster:    record.Cluster,
+		Kind:       record.Kind,
+		Namespace:  record.Namespace,
+		Name:       record.Name,
+		State:      record.State,
+		Reason:     record.Reason,
+		SloTarget:  record.SLOTarget,
+		Since:      timestamppb.New(record.Since),
+		CheckedAt:  timestamppb.New(record.CheckedAt),
+		Labels:     record.Labels,
+		DependsOn:  record.DependsOn,
+		ImpactedBy: record.ImpactedBy,
+		Silenced:   record.Silenced,
+	}
+	if record.Replicas != nil {
+		converted.Replicas = &vitalsv1.Replicas{Ready: record.Replicas.Ready, Desired: record.Replicas.Desired}
+	}
+	if record.SilencedUntil != nil {
+		converted.SilencedUntil = timestamppb.New(*record.SilencedUntil)
+	}
+	return converted
+}
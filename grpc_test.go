@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"net"
+	"testing"
+
+	vitalsv1 "github.com/vivekganesan01/k8sClusterVitals/api/vitals/v1"
+	k8client "github.com/vivekganesan01/k8sClusterVitals/internals"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialAuthenticated serves the grpc services with api key authentication on an in-memory listener
+func dialAuthenticated(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	previous := auth
+	auth = &authConfig{apiKeys: map[[sha256.Size]byte]k8client.Identity{sha256.Sum256([]byte("s3cret")): {Username: "ci", Method: "apikey"}}}
+	t.Cleanup(func() { auth = previous })
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(auth.unaryInterceptor), grpc.StreamInterceptor(auth.streamInterceptor))
+	healthpb.RegisterHealthServer(server, healthService{})
+	vitalsv1.RegisterVitalsServer(server, vitalsService{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCAuthentication(t *testing.T) {
+	seedStore(t)
+	conn := dialAuthenticated(t)
+	vitals := vitalsv1.NewVitalsClient(conn)
+	tests := []struct {
+		name string
+		md   metadata.MD
+		code codes.Code
+	}{
+		{"no credentials", nil, codes.Unauthenticated},
+		{"unknown key", metadata.Pairs("x-api-key", "wrong"), codes.Unauthenticated},
+		{"api key", metadata.Pairs("x-api-key", "s3cret"), codes.OK},
+		{"bearer", metadata.Pairs("authorization", "Bearer s3cret"), codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewOutgoingContext(context.Background(), tt.md)
+			_, err := vitals.List(ctx, &vitalsv1.ListRequest{})
+			if code := grpcstatus.Code(err); code != tt.code {
+				t.Fatalf("List: %v, want %v", err, tt.code)
+			}
+			watch, err := vitals.Watch(ctx, &vitalsv1.WatchRequest{})
+			if err == nil {
+				_, err = watch.Recv()
+			}
+			if code := grpcstatus.Code(err); code != tt.code {
+				t.Fatalf("Watch: %v, want %v", err, tt.code)
+			}
+		})
+	}
+}
+
+// kubelet grpc probes do not authenticate
+func TestGRPCHealthStaysOpen(t *testing.T) {
+	seedStore(t)
+	conn := dialAuthenticated(t)
+	response, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: "namespace/batch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("status %v, want SERVING", response.Status)
+	}
+}
+
+// the open health service does not tell anonymous callers which resources exist
+func TestGRPCHealthHidesResourcesFromAnonymousCallers(t *testing.T) {
+	seedStore(t)
+	health := healthpb.NewHealthClient(dialAuthenticated(t))
+	for _, service := range []string{"deployment/payments/api", "deployment/payments/missing"} {
+		response, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("%s: %v", service, err)
+		}
+		if response.Status != healthpb.HealthCheckResponse_SERVICE_UNKNOWN {
+			t.Fatalf("%s: status %v, want SERVICE_UNKNOWN", service, response.Status)
+		}
+	}
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-api-key", "s3cret"))
+	response, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: "deployment/payments/api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status %v, want NOT_SERVING for the degraded deployment", response.Status)
+	}
+	if _, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: "deployment/payments/missing"}); grpcstatus.Code(err) != codes.NotFound {
+		t.Fatalf("missing resource: %v, want NotFound", err)
+	}
+}
@@ -0,0 +1,145 @@
+package k8client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// how long token and access reviews are cached, the api server is not asked again for every request
+const reviewTTL = time.Minute
+
+// Identity is an authenticated caller of the http api
+type Identity struct {
+	Username string
+	UID      string
+	Groups   []string
+	Method   string // token, apikey or mtls
+}
+
+// AccessReviewer asks the api server of the home cluster who a bearer token belongs to and what its owner may read
+type AccessReviewer struct {
+	client  kubernetes.Interface
+	tokens  map[string]cachedReview // keyed by the sha256 of the token
+	access  map[string]cachedReview // keyed by user, groups and namespace
+	cacheMu sync.Mutex
+}
+
+type cachedReview struct {
+	identity Identity
+	allowed  bool
+	err      error // ErrTokenRejected, errors reaching the api server are not cached
+	expires  time.Time
+}
+
+// NewAccessReviewer creates the reviewer against the cluster k8sClusterVitals runs in, based on ENV
+func NewAccessReviewer() (*AccessReviewer, error) {
+	config, err := homeConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewAccessReviewerForClient(client), nil
+}
+
+// NewAccessReviewerForClient creates the reviewer on top of an existing client, eg: a fake one in tests
+func NewAccessReviewerForClient(client kubernetes.Interface) *AccessReviewer {
+	return &AccessReviewer{client: client, tokens: make(map[string]cachedReview), access: make(map[string]cachedReview)}
+}
+
+// ErrTokenRejected is returned for a token the api server does not authenticate
+var ErrTokenRejected = errors.New("token rejected")
+
+// ReviewToken authenticates a bearer token, eg: a service account token, with a TokenReview
+func (r *AccessReviewer) ReviewToken(ctx context.Context, token string) (Identity, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	if cached, ok := r.cached(r.tokens, key); ok {
+		return cached.identity, cached.err
+	}
+	review, err := r.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return Identity{}, err // not cached, the api server may be back on the next request
+	}
+	result := cachedReview{expires: time.Now().Add(reviewTTL)}
+	if review.Status.Authenticated {
+		result.identity = Identity{Username: review.Status.User.Username, UID: review.Status.User.UID, Groups: review.Status.User.Groups, Method: "token"}
+	} else {
+		result.err = ErrTokenRejected
+	}
+	r.store(r.tokens, key, result)
+	return result.identity, result.err
+}
+
+// CanGetDeployments asks with a SubjectAccessReview whether the identity may get deployments in the namespace,
+// an empty namespace asks for every namespace
+func (r *AccessReviewer) CanGetDeployments(ctx context.Context, identity Identity, namespace string) (bool, error) {
+	return r.review(ctx, identity, authorizationv1.ResourceAttributes{Namespace: namespace, Verb: "get", Group: "apps", Resource: "deployments"})
+}
+
+// SilencesGroup is the api group of the silences resource, it is not served by the api server and only exists to be
+// granted in RBAC, eg: verbs create and delete on silences.k8sclustervitals.io
+const SilencesGroup = "k8sclustervitals.io"
+
+// CanManageSilences asks with a SubjectAccessReview whether the identity may create or delete silences
+func (r *AccessReviewer) CanManageSilences(ctx context.Context, identity Identity, verb string) (bool, error) {
+	return r.review(ctx, identity, authorizationv1.ResourceAttributes{Verb: verb, Group: SilencesGroup, Resource: "silences"})
+}
+
+func (r *AccessReviewer) review(ctx context.Context, identity Identity, attributes authorizationv1.ResourceAttributes) (bool, error) {
+	key := helpers.LogMsg(identity.Username, "|", identity.UID, "|", strings.Join(identity.Groups, ","), "|", attributes.Verb, " ", attributes.Resource, ".", attributes.Group, "|", attributes.Namespace)
+	if cached, ok := r.cached(r.access, key); ok {
+		return cached.allowed, cached.err
+	}
+	review, err := r.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               identity.Username,
+			UID:                identity.UID,
+			Groups:             identity.Groups,
+			ResourceAttributes: &attributes,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	r.store(r.access, key, cachedReview{allowed: review.Status.Allowed, expires: time.Now().Add(reviewTTL)})
+	return review.Status.Allowed, nil
+}
+
+func (r *AccessReviewer) cached(reviews map[string]cachedReview, key string) (cachedReview, bool) {
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+	review, ok := reviews[key]
+	if !ok || time.Now().After(review.expires) {
+		return review, false
+	}
+	return review, true
+}
+
+func (r *AccessReviewer) store(reviews map[string]cachedReview, key string, review cachedReview) {
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+	now := time.Now()
+	for k, v := range reviews {
+		// lazy cleanup, the maps only hold the reviews of the last minute
+		if now.After(v.expires) {
+			delete(reviews, k)
+		}
+	}
+	reviews[key] = review
+}
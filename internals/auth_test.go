@@ -0,0 +1,69 @@
+package k8client
+
+import (
+	"context"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeReviewer allows the subject access reviews matching allow and records every review it is asked
+func fakeReviewer(allow func(authorizationv1.SubjectAccessReviewSpec) bool) (*AccessReviewer, *[]authorizationv1.SubjectAccessReviewSpec) {
+	client := fake.NewSimpleClientset()
+	var reviews []authorizationv1.SubjectAccessReviewSpec
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		reviews = append(reviews, review.Spec)
+		review.Status.Allowed = allow(review.Spec)
+		return true, review, nil
+	})
+	return NewAccessReviewerForClient(client), &reviews
+}
+
+func TestCanManageSilences(t *testing.T) {
+	reviewer, reviews := fakeReviewer(func(spec authorizationv1.SubjectAccessReviewSpec) bool {
+		return spec.User == "oncall" && spec.ResourceAttributes.Group == SilencesGroup && spec.ResourceAttributes.Resource == "silences"
+	})
+	oncall := Identity{Username: "oncall", UID: "42", Groups: []string{"sre"}}
+	tests := []struct {
+		name     string
+		identity Identity
+		verb     string
+		allowed  bool
+	}{
+		{"granted", oncall, "create", true},
+		{"cached", oncall, "create", true},
+		{"other verb", oncall, "delete", true},
+		{"reader", Identity{Username: "reader"}, "create", false},
+	}
+	for _, tt := range tests {
+		allowed, err := reviewer.CanManageSilences(context.Background(), tt.identity, tt.verb)
+		if err != nil || allowed != tt.allowed {
+			t.Fatalf("%s: allowed %v, err %v, want %v", tt.name, allowed, err, tt.allowed)
+		}
+	}
+	if len(*reviews) != 3 {
+		t.Fatalf("%d reviews, want 3 with the repeated one cached", len(*reviews))
+	}
+	first := (*reviews)[0]
+	if first.UID != "42" || first.ResourceAttributes.Verb != "create" || first.ResourceAttributes.Namespace != "" {
+		t.Fatalf("review %+v does not carry the uid and the create verb cluster wide", first)
+	}
+}
+
+// read access to deployments does not grant silences
+func TestReadAccessDoesNotManageSilences(t *testing.T) {
+	reviewer, _ := fakeReviewer(func(spec authorizationv1.SubjectAccessReviewSpec) bool {
+		return spec.ResourceAttributes.Verb == "get" && spec.ResourceAttributes.Resource == "deployments"
+	})
+	reader := Identity{Username: "reader"}
+	if allowed, _ := reviewer.CanGetDeployments(context.Background(), reader, ""); !allowed {
+		t.Fatal("reader cannot get deployments")
+	}
+	if allowed, _ := reviewer.CanManageSilences(context.Background(), reader, "create"); allowed {
+		t.Fatal("reader can create silences")
+	}
+}
@@ -0,0 +1,225 @@
+package k8client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	registry       []*Watcher       // every watcher created, one per monitored cluster
+	failedClusters map[string]error // clusters whose client could not be built, reported not ready
+	registryMu     sync.RWMutex
+)
+
+// markClusterFailed reports a cluster not ready instead of aborting the startup of the whole fleet
+func markClusterFailed(name string, err error) {
+	log.Error().Str("caller", "new_kube_clients").Str("cluster", name).Msg(helpers.LogMsg("failed to set up k8client, the cluster is reported not ready: ", err.Error()))
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if failedClusters == nil {
+		failedClusters = make(map[string]error)
+	}
+	failedClusters[name] = err
+}
+
+func registerWatcher(wc *Watcher) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, wc)
+}
+
+// ClusterReadiness reports whether the client of each monitored cluster can reach its api server, keyed by cluster name.
+// followers run no watchers and report the readiness replicated from the leader
+func ClusterReadiness() map[string]bool {
+	if Role() == RoleFollower {
+		return replicatedReadiness()
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	readiness := make(map[string]bool, len(registry)+len(failedClusters))
+	for name := range failedClusters {
+		readiness[name] = false
+	}
+	for _, wc := range registry {
+		readiness[wc.ClusterName] = wc.ready
+	}
+	return readiness
+}
+
+// ScrapeConfigurations returns the active scrape configuration of each monitored cluster, keyed by cluster name
+func ScrapeConfigurations() map[string]helpers.ScrapeConfiguration {
+	registryMu.RLock()
+	watchers := append([]*Watcher(nil), registry...)
+	registryMu.RUnlock()
+	configurations := make(map[string]helpers.ScrapeConfiguration, len(watchers))
+	for _, wc := range watchers {
+		var config helpers.ScrapeConfiguration
+		if data, err := wc.CacheStore.GoCacheGet(wc.configKey("watch.secrets.config")); err == nil {
+			config.WatchedSecrets = data.([]helpers.WatchedResource)
+		}
+		if data, err := wc.CacheStore.GoCacheGet(wc.configKey("watch.configmaps.config")); err == nil {
+			config.WatchedConfigMaps = data.([]helpers.WatchedResource)
+		}
+		if data, err := wc.CacheStore.GoCacheGet(wc.configKey("watch.customresources.config")); err == nil {
+			config.WatchedCustomResources = data.([]helpers.WatchedCustomResource)
+		}
+		if data, err := wc.CacheStore.GoCacheGet(wc.configKey("watch.expressions.config")); err == nil {
+			config.HealthExpressions = data.(map[string]string)
+		}
+		if data, err := wc.CacheStore.GoCacheGet(wc.configKey("watch.groups.config")); err == nil {
+			config.HealthGroups = data.(map[string]helpers.HealthGroup)
+		}
+		if data, err := wc.CacheStore.GoCacheGet(wc.configKey("watch.dependencies.config")); err == nil {
+			config.Dependencies = data.(map[string][]string)
+		}
+		configurations[wc.ClusterName] = config
+	}
+	return configurations
+}
+
+// markEvaluated records that a watch loop completed a full pass
+func (wc *Watcher) markEvaluated(loop string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(wc.pending, loop)
+}
+
+// WarmingUp is true until every watch loop of every cluster completed its first pass, followers until the leader snapshot is restored
+func WarmingUp() bool {
+	if Role() == RoleFollower {
+		return !replicationHealthy()
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, wc := range registry {
+		if wc.pending == nil || len(wc.pending) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// configKey scopes scrape configuration cache keys to the cluster
+func (wc *Watcher) configKey(key string) string {
+	if wc.ClusterName == "" {
+		return key
+	}
+	return helpers.LogMsg(wc.ClusterName, "/", key)
+}
+
+// statusKey prefixes status keys with the cluster name, eg: prod-eu/deployment.apps/default/nginx
+func (wc *Watcher) statusKey(key string) string {
+	if wc.ClusterName == "" {
+		return key
+	}
+	return helpers.LogMsg(wc.ClusterName, "/", key)
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// NewKubeClients builds one watcher per monitored cluster.
+// KUBE_CONTEXTS lists contexts of the kubeconfig at $KUBECONFIG (default ~/.kube/config) and KUBECONFIG_SECRETS lists
+// namespace/name secrets holding a kubeconfig key, when neither is set a single watcher is built for the home cluster as before.
+// in multi-cluster mode the home cluster is only monitored when CLUSTER_NAME is set. a cluster whose client cannot be
+// built is logged and reported not ready, the others are monitored anyway
+func NewKubeClients(cache *helpers.KeyValueStore) ([]*Watcher, error) {
+	contexts := splitList(os.Getenv("KUBE_CONTEXTS"))
+	secrets := splitList(os.Getenv("KUBECONFIG_SECRETS"))
+	if len(contexts) == 0 && len(secrets) == 0 {
+		watcher, err := NewKubeClient(cache)
+		if err != nil {
+			return nil, err
+		}
+		return []*Watcher{watcher}, nil
+	}
+	var watchers []*Watcher
+	names := make(map[string]string) // cluster name to the context or secret it came from, names must be unique
+	add := func(name, source string, config func() (*rest.Config, error)) {
+		if previous, ok := names[name]; ok {
+			// keyed by source, the first one keeps the name
+			markClusterFailed(source, fmt.Errorf("cluster name %s is already used by %s", name, previous))
+			return
+		}
+		names[name] = source
+		clusterConfig, err := config()
+		if err != nil {
+			markClusterFailed(name, fmt.Errorf("%s: %w", source, err))
+			return
+		}
+		watcher, err := newWatcher(name, clusterConfig, cache)
+		if err != nil {
+			markClusterFailed(name, fmt.Errorf("%s: %w", source, err))
+			return
+		}
+		watchers = append(watchers, watcher)
+	}
+	if name := os.Getenv("CLUSTER_NAME"); name != "" {
+		add(name, "home cluster", homeConfig)
+	}
+	for _, kubeContext := range contexts {
+		kubeContext := kubeContext
+		log.Info().Str("func", "NewKubeClients").Msg(helpers.LogMsg("setting up k8client for context ", kubeContext))
+		add(kubeContext, helpers.LogMsg("context ", kubeContext), func() (*rest.Config, error) {
+			// the -kubeconfig flag is not parsed in-cluster, read the path from $KUBECONFIG
+			return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+				clientcmd.NewDefaultClientConfigLoadingRules(),
+				&clientcmd.ConfigOverrides{CurrentContext: kubeContext},
+			).ClientConfig()
+		})
+	}
+	if len(secrets) > 0 {
+		home, err := homeClient()
+		for _, ref := range secrets {
+			ref := ref
+			namespace, name, ok := strings.Cut(ref, "/")
+			if !ok {
+				markClusterFailed(ref, errors.New(helpers.LogMsg("invalid kubeconfig secret reference ", ref, ", expected namespace/name")))
+				continue
+			}
+			log.Info().Str("func", "NewKubeClients").Msg(helpers.LogMsg("setting up k8client from secret ", ref))
+			add(name, helpers.LogMsg("secret ", ref), func() (*rest.Config, error) {
+				if err != nil {
+					return nil, err
+				}
+				secret, err := home.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+				if err != nil {
+					return nil, err
+				}
+				return clientcmd.RESTConfigFromKubeConfig(secret.Data["kubeconfig"])
+			})
+		}
+	}
+	if len(watchers) == 0 {
+		return nil, errors.New("no monitored cluster could be set up")
+	}
+	return watchers, nil
+}
+
+// homeClient reads the kubeconfig secrets from the cluster k8sClusterVitals runs in
+func homeClient() (*kubernetes.Clientset, error) {
+	config, err := homeConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
@@ -0,0 +1,61 @@
+package k8client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: local
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: prod
+  context: {cluster: local, user: admin}
+- name: staging
+  context: {cluster: local, user: admin}
+`
+
+// a cluster which cannot be set up is reported not ready, the rest of the fleet is monitored
+func TestNewKubeClientsSkipsFailingClusters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", path)
+	t.Setenv("KUBE_CONTEXTS", "prod,missing,staging,prod")
+	t.Setenv("KUBECONFIG_SECRETS", "")
+	t.Setenv("CLUSTER_NAME", "")
+	registryMu.Lock()
+	previousRegistry, previousFailed := registry, failedClusters
+	registry, failedClusters = nil, nil
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry, failedClusters = previousRegistry, previousFailed
+		registryMu.Unlock()
+	})
+
+	watchers, err := NewKubeClients(helpers.NewKeyValueStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watchers) != 2 || watchers[0].ClusterName != "prod" || watchers[1].ClusterName != "staging" {
+		t.Fatalf("watchers %v, want prod and staging", watchers)
+	}
+	readiness := ClusterReadiness()
+	for _, name := range []string{"missing", "context prod"} {
+		if ready, ok := readiness[name]; !ok || ready {
+			t.Errorf("cluster %q reported %v (listed %v), want not ready", name, ready, ok)
+		}
+	}
+}
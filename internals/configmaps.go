@@ -14,6 +14,8 @@ import (
 // watch for resource under my configmap map cache
 // alert the cache
 
+const configmaps = "configmap"
+
 func (wc *Watcher) WatchConfigMaps(ctx context.Context) {
 	defer wc.Wg.Done()
 	for {
@@ -23,26 +25,45 @@ func (wc *Watcher) WatchConfigMaps(ctx context.Context) {
 			return
 		default:
 			time.Sleep(15 * time.Second) // todo param this timer
-			data, err := wc.CacheStore.GoCacheGet("watch.configmaps.config")
-			if err != nil {
-				log.Warn().Str("caller", "watch_configmaps").Msg("watch.configmaps.config cachehit doesnt exists")
-				return
-			}
-			v := data.([]helpers.WatchedResource)
-			// todo: move to go routine and make sure its completes
-			for _, cmMetadata := range v {
-				_, err := wc.Clientset.CoreV1().ConfigMaps(cmMetadata.Namespace).Get(context.TODO(), cmMetadata.Name, metav1.GetOptions{})
-				if errors.IsNotFound(err) {
-					wc.CacheStore.Set(fmt.Sprintf("configmaps.%s/%s", cmMetadata.Namespace, cmMetadata.Name), []byte("unavailable"))
-					log.Info().Str("caller", "watch_configmaps").Msg(helpers.LogMsg("configmap not found in namespace ", cmMetadata.Name, " namespace: ", cmMetadata.Namespace))
-				} else if err != nil {
-					wc.CacheStore.Set(fmt.Sprintf("configmaps.%s/%s", cmMetadata.Namespace, cmMetadata.Name), []byte("invalid"))
-					log.Error().Str("caller", "watch_configmaps").Msg(helpers.LogMsg("error retrieving the configmap ", cmMetadata.Namespace, "-", cmMetadata.Name))
-				} else {
-					wc.CacheStore.Delete(fmt.Sprintf("configmaps.%s/%s", cmMetadata.Namespace, cmMetadata.Name))
-					log.Info().Str("caller", "watch_configmaps").Msg(helpers.LogMsg("configmap found in namespace ", cmMetadata.Name, " namespace: ", cmMetadata.Namespace))
-				}
-			}
+			wc.evaluateConfigMaps(ctx)
+			wc.markEvaluated("configmaps")
+		}
+	}
+}
+
+// evaluateConfigMaps runs a single evaluation pass over the watched and discovered configmaps
+func (wc *Watcher) evaluateConfigMaps(ctx context.Context) {
+	v := wc.watchedResources(configmaps, "watch.configmaps.config")
+	if len(v) == 0 {
+		log.Warn().Str("caller", "watch_configmaps").Msg("watch.configmaps.config cachehit doesnt exists")
+		// nothing to watch until a scrape configuration shows up, keep polling for it
+		return
+	}
+	// todo: move to go routine and make sure its completes
+	for _, cmMetadata := range v {
+		key := fmt.Sprintf("configmaps.%s/%s", cmMetadata.Namespace, cmMetadata.Name)
+		// a missing configmap keeps the labels and the silence-until annotation it was last seen with, so labelSelector
+		// queries still match it and deleting it during a maintenance window stays silenced
+		known, _ := wc.CacheStore.LastObserved(wc.statusKey(key))
+		record := helpers.StatusRecord{Kind: configmaps, Namespace: cmMetadata.Namespace, Name: cmMetadata.Name, Labels: known.Labels, SilencedUntil: known.SilencedUntil}
+		found, err := wc.Clientset.CoreV1().ConfigMaps(cmMetadata.Namespace).Get(ctx, cmMetadata.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) && cmMetadata.Optional {
+			record.State = helpers.StateHealthy
+			log.Info().Str("caller", "watch_configmaps").Msg(helpers.LogMsg("optional configmap not found in namespace ", cmMetadata.Name, " namespace: ", cmMetadata.Namespace))
+		} else if errors.IsNotFound(err) {
+			record.State = helpers.StateUnavailable
+			record.Reason = "not found"
+			log.Info().Str("caller", "watch_configmaps").Msg(helpers.LogMsg("configmap not found in namespace ", cmMetadata.Name, " namespace: ", cmMetadata.Namespace))
+		} else if err != nil {
+			record.State = helpers.StateInvalid
+			record.Reason = err.Error()
+			log.Error().Str("caller", "watch_configmaps").Msg(helpers.LogMsg("error retrieving the configmap ", cmMetadata.Namespace, "-", cmMetadata.Name))
+		} else {
+			record.State = helpers.StateHealthy
+			record.Labels = found.Labels
+			record.SilencedUntil = silenceUntil(found.Annotations)
+			log.Info().Str("caller", "watch_configmaps").Msg(helpers.LogMsg("configmap found in namespace ", cmMetadata.Name, " namespace: ", cmMetadata.Namespace))
 		}
+		wc.report(key, record)
 	}
 }
@@ -0,0 +1,134 @@
+package k8client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// evaluateRule returns a failure message when the object does not satisfy the rule
+func evaluateRule(obj *unstructured.Unstructured, rule helpers.HealthRule) (string, bool) {
+	if rule.Condition != "" {
+		want := rule.Status
+		if want == "" {
+			want = "True"
+		}
+		conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["type"] != rule.Condition {
+				continue
+			}
+			if status, _ := condition["status"].(string); status != want {
+				failure := fmt.Sprintf("condition %s is %s, expected %s", rule.Condition, status, want)
+				if message, _ := condition["message"].(string); message != "" {
+					failure = helpers.LogMsg(failure, ": ", message)
+				}
+				return failure, false
+			}
+			return "", true
+		}
+		return fmt.Sprintf("condition %s not reported", rule.Condition), false
+	}
+	if rule.JSONPath != "" {
+		j := jsonpath.New("rule").AllowMissingKeys(true)
+		if err := j.Parse(rule.JSONPath); err != nil {
+			return fmt.Sprintf("invalid jsonPath %s: %s", rule.JSONPath, err.Error()), false
+		}
+		var buf bytes.Buffer
+		if err := j.Execute(&buf, obj.Object); err != nil {
+			return fmt.Sprintf("jsonPath %s failed: %s", rule.JSONPath, err.Error()), false
+		}
+		if got := buf.String(); got != rule.Equals {
+			return fmt.Sprintf("%s is %q, expected %q", rule.JSONPath, got, rule.Equals), false
+		}
+	}
+	return "", true
+}
+
+func (wc *Watcher) checkCustomResourceHealth(ctx context.Context, gvr schema.GroupVersionResource, obj *unstructured.Unstructured, rules []helpers.HealthRule) {
+	kind := strings.ToLower(obj.GetKind())
+	record := helpers.StatusRecord{Kind: kind, Namespace: obj.GetNamespace(), Name: obj.GetName(), State: helpers.StateHealthy, SLOTarget: sloTarget(obj.GetAnnotations()), SilencedUntil: silenceUntil(obj.GetAnnotations()), Labels: obj.GetLabels()}
+	key := fmt.Sprintf("%s/%s/%s", gvr.GroupResource().String(), obj.GetNamespace(), obj.GetName())
+	if expression := wc.healthExpression(kind, obj.GetAnnotations()); expression != "" {
+		record.State, record.Reason = wc.evaluateHealthExpression(ctx, expression, obj, obj.GetNamespace(), nil)
+		log.Info().Str("caller", "check_custom_resource_health").Str("tag", kind).Str("namespace", obj.GetNamespace()).Msg(helpers.LogMsg(kind, " health expression evaluated: ", obj.GetName(), ", state: ", record.State))
+		wc.report(key, record)
+		return
+	}
+	var failures []string
+	for _, rule := range rules {
+		message, ok := evaluateRule(obj, rule)
+		if ok {
+			continue
+		}
+		failures = append(failures, message)
+		state := rule.State
+		if state == "" {
+			state = helpers.StateUnavailable
+		}
+		// the worst state of the failing rules wins
+		record.State = helpers.WorseState(record.State, state)
+	}
+	if len(failures) > 0 {
+		record.Reason = strings.Join(failures, "; ")
+		log.Error().Str("caller", "check_custom_resource_health").Str("tag", kind).Str("namespace", obj.GetNamespace()).Msg(helpers.LogMsg(kind, " is not healthy: ", obj.GetName(), ", ", record.Reason))
+	} else {
+		log.Info().Str("caller", "check_custom_resource_health").Str("tag", kind).Str("namespace", obj.GetNamespace()).Msg(helpers.LogMsg(kind, " is healthy: ", obj.GetName()))
+	}
+	wc.report(key, record)
+}
+
+func (wc *Watcher) WatchCustomResources(ctx context.Context) {
+	defer wc.Wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Str("caller", "watch_custom_resources").Msg("gracefully shutting down custom resource watch")
+			return
+		default:
+			time.Sleep(15 * time.Second) // todo: param this timer
+			wc.evaluateCustomResources(ctx)
+			wc.markEvaluated("customresources")
+		}
+	}
+}
+
+// evaluateCustomResources runs a single evaluation pass over the custom resources of the scrape configuration
+func (wc *Watcher) evaluateCustomResources(ctx context.Context) {
+	data, err := wc.CacheStore.GoCacheGet(wc.configKey("watch.customresources.config"))
+	if err != nil {
+		log.Debug().Str("caller", "watch_custom_resources").Msg("watch.customresources.config cachehit doesnt exists")
+		return
+	}
+	for _, watched := range data.([]helpers.WatchedCustomResource) {
+		namespace := watched.Namespace
+		if wc.Namespace != "" {
+			if namespace != "" && namespace != wc.Namespace {
+				continue
+			}
+			namespace = wc.Namespace
+		}
+		gvr := schema.GroupVersionResource{Group: watched.Group, Version: watched.Version, Resource: watched.Resource}
+		list, err := wc.DynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: watched.LabelSelector,
+		})
+		if err != nil {
+			log.Error().Str("caller", "watch_custom_resources").Msg(helpers.LogMsg("failed to list ", gvr.String(), ": ", err.Error()))
+			continue
+		}
+		for i := range list.Items {
+			wc.checkCustomResourceHealth(ctx, gvr, &list.Items[i], watched.Rules)
+		}
+	}
+}
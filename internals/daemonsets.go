@@ -0,0 +1,77 @@
+package k8client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+
+	v1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const daemonsets = "daemonset"
+
+func (wc *Watcher) checkDaemonSetHealth(ctx context.Context, daemonSet *v1.DaemonSet, initialDelaySeconds int16) {
+	time.Sleep(time.Duration(initialDelaySeconds) * time.Second)
+	record := helpers.StatusRecord{Kind: daemonsets, Namespace: daemonSet.Namespace, Name: daemonSet.Name, State: helpers.StateHealthy, SLOTarget: sloTarget(daemonSet.Annotations), SilencedUntil: silenceUntil(daemonSet.Annotations), Labels: daemonSet.Labels,
+		DependsOn: inferredDependencies(daemonSet.Namespace, &daemonSet.Spec.Template.Spec), Replicas: &helpers.Replicas{Ready: daemonSet.Status.NumberAvailable, Desired: daemonSet.Status.DesiredNumberScheduled}}
+	status := daemonSet.Status
+	if expression := wc.healthExpression(daemonsets, daemonSet.Annotations); expression != "" {
+		record.State, record.Reason = wc.evaluateHealthExpression(ctx, expression, daemonSet, daemonSet.Namespace, daemonSet.Spec.Selector)
+		log.Info().Str("caller", "check_daemonset_health").Str("tag", daemonsets).Str("namespace", daemonSet.Namespace).Msg(helpers.LogMsg("daemonset health expression evaluated: ", daemonSet.Name, ", state: ", record.State))
+	} else if status.NumberAvailable < status.DesiredNumberScheduled || status.NumberUnavailable > 0 {
+		record.State = helpers.StateUnavailable
+		record.Reason = fmt.Sprintf("%d/%d available", status.NumberAvailable, status.DesiredNumberScheduled)
+		log.Error().Str("caller", "check_daemonset_health").Str("tag", daemonsets).Str("namespace", daemonSet.Namespace).Msg(helpers.LogMsg("daemonset is not healthy: ", daemonSet.Name, ", ", record.Reason))
+	} else if status.NumberMisscheduled > 0 {
+		record.State = helpers.StateDegraded
+		record.Reason = fmt.Sprintf("%d pods running on nodes they should not run on", status.NumberMisscheduled)
+		log.Warn().Str("caller", "check_daemonset_health").Str("tag", daemonsets).Str("namespace", daemonSet.Namespace).Msg(helpers.LogMsg("daemonset is degraded: ", daemonSet.Name, ", ", record.Reason))
+	} else {
+		log.Info().Str("caller", "check_daemonset_health").Str("tag", daemonsets).Str("namespace", daemonSet.Namespace).Msg(helpers.LogMsg("daemonset is healthy: ", daemonSet.Name))
+	}
+	wc.discoverReferences(wc.statusKey(fmt.Sprintf("daemonset.apps/%s/%s", daemonSet.Namespace, daemonSet.Name)), daemonSet.Namespace, &daemonSet.Spec.Template.Spec)
+	wc.report(fmt.Sprintf("daemonset.apps/%s/%s", daemonSet.Namespace, daemonSet.Name), record)
+}
+
+func (wc *Watcher) WatchDaemonSets(ctx context.Context, LabelSelector string) {
+	defer wc.Wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Str("caller", "watch_daemonsets").Msg("gracefully shutting down daemonset watch")
+			return
+		default:
+			time.Sleep(15 * time.Second) // todo: param this timer
+			if err := wc.evaluateDaemonSets(ctx, LabelSelector); err != nil {
+				log.Info().Str("caller", "watch_daemonsets").Msg("no daemonset has been found")
+				continue
+			}
+			wc.markEvaluated("daemonsets")
+		}
+	}
+}
+
+// evaluateDaemonSets runs a single evaluation pass over the labelled daemonsets
+func (wc *Watcher) evaluateDaemonSets(ctx context.Context, LabelSelector string) error {
+	daemonSets, err := wc.Clientset.AppsV1().DaemonSets(wc.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: LabelSelector,
+	})
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for _, daemonSet := range daemonSets.Items {
+		wg.Add(1)
+		go func(daemonSet v1.DaemonSet) {
+			defer wg.Done()
+			wc.checkDaemonSetHealth(ctx, &daemonSet, wc.initialDelaySeconds())
+		}(daemonSet)
+	}
+	wg.Wait()
+	return nil
+}
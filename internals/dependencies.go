@@ -0,0 +1,142 @@
+package k8client
+
+import (
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podReference is a Secret or ConfigMap referenced by a pod template
+type podReference struct {
+	Kind     string // secret or configmap
+	Name     string
+	Optional bool // every reference to it is marked optional: true, the pod starts without it
+}
+
+// podReferences walks envFrom, env valueFrom, the secret, configMap and projected volumes and the imagePullSecrets
+// of the pod template
+func podReferences(spec *corev1.PodSpec) []podReference {
+	refs := make(map[podReference]bool) // whether every reference is optional, keyed by kind and name
+	add := func(kind, name string, optional *bool) {
+		if name == "" {
+			return
+		}
+		ref := podReference{Kind: kind, Name: name}
+		isOptional := optional != nil && *optional
+		if seen, ok := refs[ref]; ok {
+			isOptional = isOptional && seen
+		}
+		refs[ref] = isOptional
+	}
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				add(secrets, envFrom.SecretRef.Name, envFrom.SecretRef.Optional)
+			}
+			if envFrom.ConfigMapRef != nil {
+				add(configmaps, envFrom.ConfigMapRef.Name, envFrom.ConfigMapRef.Optional)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				add(secrets, ref.Name, ref.Optional)
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				add(configmaps, ref.Name, ref.Optional)
+			}
+		}
+	}
+	for _, pullSecret := range spec.ImagePullSecrets {
+		add(secrets, pullSecret.Name, nil)
+	}
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil {
+			add(secrets, volume.Secret.SecretName, volume.Secret.Optional)
+		}
+		if volume.ConfigMap != nil {
+			add(configmaps, volume.ConfigMap.Name, volume.ConfigMap.Optional)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					add(secrets, source.Secret.Name, source.Secret.Optional)
+				}
+				if source.ConfigMap != nil {
+					add(configmaps, source.ConfigMap.Name, source.ConfigMap.Optional)
+				}
+			}
+		}
+	}
+	references := make([]podReference, 0, len(refs))
+	for ref, optional := range refs {
+		ref.Optional = optional
+		references = append(references, ref)
+	}
+	sort.Slice(references, func(i, j int) bool {
+		if references[i].Kind != references[j].Kind {
+			return references[i].Kind < references[j].Kind
+		}
+		return references[i].Name < references[j].Name
+	})
+	return references
+}
+
+// inferredDependencies are the required Secrets and ConfigMaps of the pod template, optional ones do not impact the workload
+func inferredDependencies(namespace string, spec *corev1.PodSpec) []string {
+	var dependencies []string
+	for _, ref := range podReferences(spec) {
+		if !ref.Optional {
+			dependencies = append(dependencies, helpers.LogMsg(ref.Kind, "/", namespace, "/", ref.Name))
+		}
+	}
+	return dependencies
+}
+
+// configuredDependencies are the dependencies declared for the record in the dependencies key of the scrape configuration,
+// eg: deployment/default/api: [statefulset/postgres, secret/db-creds]. references without namespace resolve in the
+// namespace of the dependent
+func (wc *Watcher) configuredDependencies(record helpers.StatusRecord) []string {
+	data, err := wc.CacheStore.GoCacheGet(wc.configKey("watch.dependencies.config"))
+	if err != nil {
+		return nil
+	}
+	var dependencies []string
+	for dependent, refs := range data.(map[string][]string) {
+		filter, err := helpers.ParseResourceRef(dependent)
+		if err != nil || !filter.Matches(helpers.StatusRecord{Kind: record.Kind, Namespace: record.Namespace, Name: record.Name}) {
+			continue
+		}
+		for _, ref := range refs {
+			dependency, err := helpers.ParseResourceRef(ref)
+			if err != nil {
+				log.Warn().Str("caller", "configured_dependencies").Msg(helpers.LogMsg("ignoring dependency of ", dependent, ": ", err.Error()))
+				continue
+			}
+			if dependency.Namespace == "" {
+				dependency.Namespace = record.Namespace
+			}
+			dependencies = append(dependencies, helpers.LogMsg(dependency.Kind, "/", dependency.Namespace, "/", dependency.Name))
+		}
+	}
+	return dependencies
+}
+
+// dedupe keeps the first occurrence of every item, sorted
+func dedupe(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	var unique []string
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			unique = append(unique, item)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -15,16 +16,26 @@ import (
 
 const deployments = "deployment"
 
-func (wc *Watcher) checkDeploymentHealth(deploy *v1.Deployment, initialDelaySeconds int16) {
+func (wc *Watcher) checkDeploymentHealth(ctx context.Context, deploy *v1.Deployment, initialDelaySeconds int16) {
 	time.Sleep(time.Duration(initialDelaySeconds) * time.Second) //todo: customised param for all the timers
-	if deploy.Status.AvailableReplicas == *deploy.Spec.Replicas && deploy.Status.UnavailableReplicas == 0 {
+	record := helpers.StatusRecord{Kind: deployments, Namespace: deploy.Namespace, Name: deploy.Name, State: helpers.StateHealthy, SLOTarget: sloTarget(deploy.Annotations), SilencedUntil: silenceUntil(deploy.Annotations), Labels: deploy.Labels,
+		DependsOn: inferredDependencies(deploy.Namespace, &deploy.Spec.Template.Spec), Replicas: &helpers.Replicas{Ready: deploy.Status.AvailableReplicas, Desired: *deploy.Spec.Replicas}}
+	if expression := wc.healthExpression(deployments, deploy.Annotations); expression != "" {
+		record.State, record.Reason = wc.evaluateHealthExpression(ctx, expression, deploy, deploy.Namespace, deploy.Spec.Selector)
+		log.Info().Str("caller", "check_deployment_health").Str("tag", deployments).Str("namespace", deploy.Namespace).Msg(helpers.LogMsg("deployment health expression evaluated: ", deploy.Name, ", state: ", record.State))
+	} else if deploy.Status.AvailableReplicas == *deploy.Spec.Replicas && deploy.Status.UnavailableReplicas == 0 {
 		log.Info().Str("caller", "check_deployment_health").Str("tag", deployments).Str("namespace", deploy.Namespace).Msg(helpers.LogMsg("deployment is healthy: ", deploy.Name))
-		wc.CacheStore.Delete(fmt.Sprintf("deployment.apps/%s", deploy.Name))
 	} else {
-		// todo: to reduce some work on cache, check for key existance first and set the cache
-		wc.CacheStore.Set(fmt.Sprintf("deployment.apps/%s", deploy.Name), []byte("unavailable"))
+		record.State = helpers.StateUnavailable
+		record.Reason = fmt.Sprintf("%d/%d available", deploy.Status.AvailableReplicas, *deploy.Spec.Replicas)
 		log.Error().Str("caller", "check_deployment_health").Str("tag", deployments).Str("namespace", deploy.Namespace).Msg(helpers.LogMsg("deployment is not healthy: ", deploy.Name, ", unavailable: ", strconv.FormatInt(int64(deploy.Status.UnavailableReplicas), 10)))
 	}
+	if reason, ok := wc.applyAutoscalerFinding(&record, "Deployment", deploy.Namespace, deploy.Name); ok {
+		log.Warn().Str("caller", "check_deployment_health").Str("tag", deployments).Str("namespace", deploy.Namespace).Msg(helpers.LogMsg("deployment autoscaler is not healthy: ", deploy.Name, ", ", reason))
+	}
+	wc.discoverReferences(wc.statusKey(fmt.Sprintf("deployment.apps/%s/%s", deploy.Namespace, deploy.Name)), deploy.Namespace, &deploy.Spec.Template.Spec)
+	// todo: to reduce some work on cache, check for key existance first and set the cache
+	wc.report(fmt.Sprintf("deployment.apps/%s/%s", deploy.Namespace, deploy.Name), record)
 }
 
 func (wc *Watcher) WatchDeployment(ctx context.Context, LabelSelector string) {
@@ -36,19 +47,31 @@ func (wc *Watcher) WatchDeployment(ctx context.Context, LabelSelector string) {
 			return
 		default:
 			time.Sleep(15 * time.Second) // todo: param this timer
-			deployments, err := wc.Clientset.AppsV1().Deployments("").List(context.TODO(), metav1.ListOptions{
-				LabelSelector: LabelSelector,
-			})
-			if err != nil {
+			if err := wc.evaluateDeployments(ctx, LabelSelector); err != nil {
 				log.Info().Str("caller", "watch_deployment").Msg("no deployment has been found")
 				continue
 			}
-			for _, deployment := range deployments.Items {
-				// todo: may be need to add context here as well ?
-				go func(deployment v1.Deployment) {
-					wc.checkDeploymentHealth(&deployment, 3)
-				}(deployment)
-			}
+			wc.markEvaluated("deployments")
 		}
 	}
 }
+
+// evaluateDeployments runs a single evaluation pass over the labelled deployments
+func (wc *Watcher) evaluateDeployments(ctx context.Context, LabelSelector string) error {
+	deployments, err := wc.Clientset.AppsV1().Deployments(wc.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: LabelSelector,
+	})
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for _, deployment := range deployments.Items {
+		wg.Add(1)
+		go func(deployment v1.Deployment) {
+			defer wg.Done()
+			wc.checkDeploymentHealth(ctx, &deployment, wc.initialDelaySeconds())
+		}(deployment)
+	}
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,89 @@
+package k8client
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// workloads not evaluated for this long no longer keep their references tracked
+const discoveryTTL = 5 * time.Minute
+
+// discoveredWorkload holds the Secrets and ConfigMaps referenced by the pod template of a scraped workload
+type discoveredWorkload struct {
+	Namespace  string
+	References []podReference
+	SeenAt     time.Time
+}
+
+// AutoDiscoveryEnabled tracks every Secret and ConfigMap referenced by scraped workloads on top of the watched-secrets
+// and watched-configmaps of the scrape configuration
+func AutoDiscoveryEnabled() bool {
+	return os.Getenv("AUTO_DISCOVER_REFERENCES") == "true"
+}
+
+// discoverReferences records the references of the workload behind the key, replacing the previous ones
+func (wc *Watcher) discoverReferences(key, namespace string, spec *corev1.PodSpec) {
+	if !AutoDiscoveryEnabled() {
+		return
+	}
+	wc.discoveredMu.Lock()
+	defer wc.discoveredMu.Unlock()
+	wc.discovered[key] = discoveredWorkload{Namespace: namespace, References: podReferences(spec), SeenAt: time.Now()}
+}
+
+// watchedResources merges the resources of the kind listed in the scrape configuration under configKey with the
+// discovered ones. a discovered reference is optional only when every workload referencing it marks it optional
+func (wc *Watcher) watchedResources(kind, configKey string) []helpers.WatchedResource {
+	merged := make(map[helpers.WatchedResource]bool) // optional, keyed with Optional unset
+	if data, err := wc.CacheStore.GoCacheGet(wc.configKey(configKey)); err == nil {
+		for _, resource := range data.([]helpers.WatchedResource) {
+			optional := resource.Optional
+			resource.Optional = false
+			merged[resource] = optional
+		}
+	}
+	wc.discoveredMu.Lock()
+	discovered := make(map[helpers.WatchedResource]bool)
+	for key, workload := range wc.discovered {
+		if time.Since(workload.SeenAt) > discoveryTTL {
+			delete(wc.discovered, key)
+			continue
+		}
+		for _, ref := range workload.References {
+			if ref.Kind != kind {
+				continue
+			}
+			resource := helpers.WatchedResource{Name: ref.Name, Namespace: workload.Namespace}
+			if optional, ok := discovered[resource]; ok {
+				discovered[resource] = optional && ref.Optional
+			} else {
+				discovered[resource] = ref.Optional
+			}
+		}
+	}
+	wc.discoveredMu.Unlock()
+	for resource, optional := range discovered {
+		if _, ok := merged[resource]; !ok { // the scrape configuration wins
+			merged[resource] = optional
+		}
+	}
+	resources := make([]helpers.WatchedResource, 0, len(merged))
+	for resource, optional := range merged {
+		if wc.Namespace != "" && resource.Namespace != wc.Namespace {
+			continue
+		}
+		resource.Optional = optional
+		resources = append(resources, resource)
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Namespace != resources[j].Namespace {
+			return resources[i].Namespace < resources[j].Namespace
+		}
+		return resources[i].Name < resources[j].Name
+	})
+	return resources
+}
@@ -0,0 +1,87 @@
+package k8client
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// NewLocalKubeClient builds a watcher from the kubeconfig of the user, used by the cli. an empty path follows
+// $KUBECONFIG and ~/.kube/config, an empty context uses the current one
+func NewLocalKubeClient(cache *helpers.KeyValueStore, kubeconfigPath, kubeContext string) (*Watcher, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfigPath != "" {
+		rules.ExplicitPath = kubeconfigPath
+	}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newWatcher("", config, cache)
+}
+
+// KubeconfigNamespace returns the namespace of the kubeconfig context, default when the context sets none
+func KubeconfigNamespace(kubeconfigPath, kubeContext string) string {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfigPath != "" {
+		rules.ExplicitPath = kubeconfigPath
+	}
+	namespace, _, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext}).Namespace()
+	if err != nil || namespace == "" {
+		return "default"
+	}
+	return namespace
+}
+
+// loadScrapeConfig reads the scrape configuration once instead of watching it
+func (wc *Watcher) loadScrapeConfig(ctx context.Context) error {
+	configMaps, err := wc.Clientset.CoreV1().ConfigMaps("").List(ctx, metav1.ListOptions{LabelSelector: "k8sclustervitals.io/config=exists"})
+	if err != nil {
+		return err
+	}
+	for i := range configMaps.Items {
+		wc.syncScrapeConfiguration(&configMaps.Items[i], "loaded")
+	}
+	return nil
+}
+
+// EvaluateOnce runs a single evaluation pass of every watch loop without the hysteresis, autoscalers first so that their
+// findings apply to the workloads and workloads before secrets and configmaps so that their references are discovered
+func (wc *Watcher) EvaluateOnce(ctx context.Context, LabelSelector string) error {
+	wc.once = true
+	if err := wc.loadScrapeConfig(ctx); err != nil {
+		// the scrape configuration usually lives in another namespace, carry on without it
+		log.Warn().Str("caller", "evaluate_once").Msg(helpers.LogMsg("failed to load the scrape configuration: ", err.Error()))
+	}
+	passes := []func() error{
+		func() error { return wc.evaluateHorizontalPodAutoscalers(ctx, LabelSelector) },
+		func() error { return wc.evaluateDeployments(ctx, LabelSelector) },
+		func() error { return wc.evaluateStatefulSets(ctx, LabelSelector) },
+		func() error { return wc.evaluateDaemonSets(ctx, LabelSelector) },
+		func() error { return wc.evaluateDisruptionBudgets(ctx, LabelSelector) },
+		func() error { wc.evaluateCustomResources(ctx); return nil },
+		func() error { wc.evaluateSecrets(ctx); return nil },
+		func() error { wc.evaluateConfigMaps(ctx); return nil },
+	}
+	for _, pass := range passes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := pass(); err != nil {
+			return err
+		}
+	}
+	// calls cut short by the context fail on their own, the pass is incomplete
+	return ctx.Err()
+}
+
+// initialDelaySeconds gives new workloads a moment to settle, a single pass evaluates right away
+func (wc *Watcher) initialDelaySeconds() int16 {
+	if wc.once {
+		return 0
+	}
+	return 3
+}
@@ -0,0 +1,162 @@
+package k8client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/lru"
+)
+
+// per object override of the per kind health-expressions configuration
+const healthExpressionAnnotation = "k8sclustervitals.io/health-expression"
+
+const (
+	// cost budget of a single evaluation, the same per call limit kubernetes applies to validation rules. an
+	// annotation cannot stall the watch loop with an unbounded expression
+	celCostLimit = 1000000
+	// compiled expressions kept, annotations are written by anyone who can edit a workload
+	celProgramCacheSize = 256
+)
+
+var (
+	celEnv      *cel.Env
+	celPrograms = lru.New(celProgramCacheSize) // compiledExpression keyed by source
+	celMu       sync.Mutex
+)
+
+type compiledExpression struct {
+	program  cel.Program
+	usesPods bool // the checked expression references the pods variable
+}
+
+func init() {
+	var err error
+	celEnv, err = cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("pods", cel.ListType(cel.DynType)),
+		cel.Variable("now", cel.TimestampType),
+	)
+	if err != nil {
+		log.Fatal().Str("caller", "expressions_init").Msg(helpers.LogMsg("failed to create cel environment: ", err.Error()))
+	}
+}
+
+// healthExpression returns the expression to evaluate for an object, the annotation wins over the per kind configuration
+func (wc *Watcher) healthExpression(kind string, annotations map[string]string) string {
+	if expression, ok := annotations[healthExpressionAnnotation]; ok && expression != "" {
+		return expression
+	}
+	data, err := wc.CacheStore.GoCacheGet(wc.configKey("watch.expressions.config"))
+	if err != nil {
+		return ""
+	}
+	return data.(map[string]string)[kind]
+}
+
+func compileExpression(expression string) (compiledExpression, error) {
+	celMu.Lock()
+	defer celMu.Unlock()
+	if compiled, ok := celPrograms.Get(expression); ok {
+		return compiled.(compiledExpression), nil
+	}
+	ast, issues := celEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return compiledExpression{}, issues.Err()
+	}
+	program, err := celEnv.Program(ast, cel.CostLimit(celCostLimit))
+	if err != nil {
+		return compiledExpression{}, err
+	}
+	checked, err := cel.AstToCheckedExpr(ast)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+	compiled := compiledExpression{program: program}
+	for _, reference := range checked.GetReferenceMap() {
+		// comments and string literals do not show up as references
+		if reference.GetName() == "pods" {
+			compiled.usesPods = true
+		}
+	}
+	celPrograms.Add(expression, compiled)
+	return compiled, nil
+}
+
+// evaluateHealthExpression runs the expression against the object and returns the state and message.
+// the expression may return a bool, a state string (healthy, degraded, unhealthy) or a map with state and message keys.
+// pods matching the selector are only listed when the expression refers to them.
+func (wc *Watcher) evaluateHealthExpression(ctx context.Context, expression string, object runtime.Object, namespace string, selector *metav1.LabelSelector) (string, string) {
+	compiled, err := compileExpression(expression)
+	if err != nil {
+		return helpers.StateInvalid, helpers.LogMsg("invalid health expression: ", err.Error())
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return helpers.StateInvalid, err.Error()
+	}
+	pods := []interface{}{}
+	if selector != nil && compiled.usesPods {
+		podSelector, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return helpers.StateInvalid, err.Error()
+		}
+		podList, err := wc.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: podSelector.String()})
+		if err != nil {
+			return helpers.StateInvalid, helpers.LogMsg("failed to list pods: ", err.Error())
+		}
+		for i := range podList.Items {
+			pod, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&podList.Items[i])
+			if err != nil {
+				return helpers.StateInvalid, err.Error()
+			}
+			pods = append(pods, pod)
+		}
+	}
+	out, _, err := compiled.program.Eval(map[string]interface{}{"object": obj, "pods": pods, "now": time.Now()})
+	if err != nil {
+		return helpers.StateInvalid, helpers.LogMsg("health expression failed: ", err.Error())
+	}
+	return expressionResult(out)
+}
+
+func expressionResult(out ref.Val) (string, string) {
+	switch v := out.(type) {
+	case types.Bool:
+		if v {
+			return helpers.StateHealthy, ""
+		}
+		return helpers.StateUnavailable, "health expression is false"
+	case types.String:
+		return expressionState(string(v)), ""
+	}
+	result, err := out.ConvertToNative(reflect.TypeOf(map[string]string{}))
+	if err != nil {
+		return helpers.StateInvalid, fmt.Sprintf("health expression returned unsupported type %s", out.Type().TypeName())
+	}
+	m := result.(map[string]string)
+	return expressionState(m["state"]), m["message"]
+}
+
+func expressionState(state string) string {
+	switch strings.ToLower(state) {
+	case helpers.StateHealthy:
+		return helpers.StateHealthy
+	case helpers.StateDegraded:
+		return helpers.StateDegraded
+	case "unhealthy", helpers.StateUnavailable:
+		return helpers.StateUnavailable
+	}
+	return helpers.StateInvalid
+}
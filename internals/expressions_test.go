@@ -0,0 +1,58 @@
+package k8client
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileExpressionPodsReference(t *testing.T) {
+	tests := []struct {
+		expression string
+		usesPods   bool
+	}{
+		{"object.status.readyReplicas >= 1", false},
+		{"object.metadata.name != 'pods'", false},
+		{"// pods are not needed\nobject.status.readyReplicas >= 1", false},
+		{"object.metadata.labels.all(k, k != 'pods')", false},
+		{"size(pods) > 0", true},
+		{"pods.exists(p, p.status.phase == 'Failed') ? 'degraded' : 'healthy'", true},
+	}
+	for _, tt := range tests {
+		compiled, err := compileExpression(tt.expression)
+		if err != nil {
+			t.Fatalf("%q: %s", tt.expression, err)
+		}
+		if compiled.usesPods != tt.usesPods {
+			t.Errorf("%q: usesPods = %v, want %v", tt.expression, compiled.usesPods, tt.usesPods)
+		}
+	}
+}
+
+// an expression over the budget fails instead of holding the watch loop
+func TestExpressionCostLimit(t *testing.T) {
+	compiled, err := compileExpression("pods.exists(a, pods.exists(b, pods.exists(c, c.metadata.name == 'none')))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods := make([]interface{}, 200)
+	for i := range pods {
+		pods[i] = map[string]interface{}{"metadata": map[string]interface{}{"name": fmt.Sprint(i)}}
+	}
+	_, _, err = compiled.program.Eval(map[string]interface{}{"object": map[string]interface{}{}, "pods": pods, "now": time.Now()})
+	if err == nil || !strings.Contains(err.Error(), "cost limit") {
+		t.Fatalf("err = %v, want the cost limit to be exceeded", err)
+	}
+}
+
+func TestCompiledExpressionsAreBounded(t *testing.T) {
+	for i := 0; i < celProgramCacheSize+10; i++ {
+		if _, err := compileExpression(fmt.Sprintf("object.spec.replicas == %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := celPrograms.Len(); n > celProgramCacheSize {
+		t.Fatalf("%d compiled expressions kept, want at most %d", n, celProgramCacheSize)
+	}
+}
@@ -0,0 +1,36 @@
+package k8client
+
+import (
+	"sort"
+
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// HealthGroups returns the health groups defined in the scrape configuration of every monitored cluster sorted by name,
+// only the ones of the given cluster when it is not empty
+func HealthGroups(cluster string) []helpers.HealthGroup {
+	registryMu.RLock()
+	watchers := append([]*Watcher(nil), registry...)
+	registryMu.RUnlock()
+	var groups []helpers.HealthGroup
+	for _, wc := range watchers {
+		if cluster != "" && wc.ClusterName != cluster {
+			continue
+		}
+		data, err := wc.CacheStore.GoCacheGet(wc.configKey("watch.groups.config"))
+		if err != nil {
+			continue
+		}
+		for name, group := range data.(map[string]helpers.HealthGroup) {
+			group.Name, group.Cluster = name, wc.ClusterName
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Name == groups[j].Name {
+			return groups[i].Cluster < groups[j].Cluster
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
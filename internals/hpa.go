@@ -0,0 +1,155 @@
+package k8client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const horizontalPodAutoscalers = "horizontalpodautoscaler"
+
+// how long an autoscaler may sit at maxReplicas before the workload is reported as degraded
+var hpaSaturationWindow = helpers.GetEnvDuration("HPA_SATURATION_WINDOW", 5*time.Minute)
+
+// autoscalerFinding returns the autoscaler problems recorded against a scale target, eg: Deployment/default/nginx
+func (wc *Watcher) autoscalerFinding(kind, namespace, name string) (string, bool) {
+	wc.hpaMu.RLock()
+	defer wc.hpaMu.RUnlock()
+	reason, ok := wc.hpaFindings[fmt.Sprintf("%s/%s/%s", kind, namespace, name)]
+	return reason, ok
+}
+
+// applyAutoscalerFinding degrades a healthy workload record when its autoscaler has problems, otherwise appends to the reason
+func (wc *Watcher) applyAutoscalerFinding(record *helpers.StatusRecord, kind, namespace, name string) (string, bool) {
+	reason, ok := wc.autoscalerFinding(kind, namespace, name)
+	if !ok {
+		return "", false
+	}
+	if record.State == helpers.StateHealthy {
+		record.State = helpers.StateDegraded
+		record.Reason = reason
+	} else {
+		record.Reason = helpers.LogMsg(record.Reason, "; ", reason)
+	}
+	return reason, true
+}
+
+// checkAutoscalerHealth returns the problems found on the autoscaler, empty when the autoscaler is healthy
+func (wc *Watcher) checkAutoscalerHealth(hpa *autoscalingv2.HorizontalPodAutoscaler) []string {
+	var problems []string
+	id := fmt.Sprintf("%s/%s", hpa.Namespace, hpa.Name)
+	// a pinned autoscaler (minReplicas == maxReplicas) always runs at max, that is not saturation
+	minReplicas := int32(1) // the api server default
+	if hpa.Spec.MinReplicas != nil {
+		minReplicas = *hpa.Spec.MinReplicas
+	}
+	if minReplicas < hpa.Spec.MaxReplicas && hpa.Status.CurrentReplicas > 0 && hpa.Status.CurrentReplicas == hpa.Spec.MaxReplicas {
+		since, ok := wc.hpaSaturatedSince[id]
+		if !ok {
+			since = time.Now()
+			wc.hpaSaturatedSince[id] = since
+		}
+		if saturatedFor := time.Since(since); saturatedFor >= hpaSaturationWindow {
+			problems = append(problems, fmt.Sprintf("hpa %s saturated at max replicas %d for %s", hpa.Name, hpa.Spec.MaxReplicas, saturatedFor.Round(time.Second)))
+		}
+	} else {
+		delete(wc.hpaSaturatedSince, id)
+	}
+	for _, condition := range hpa.Status.Conditions {
+		if condition.Status != corev1.ConditionFalse {
+			continue
+		}
+		switch condition.Type {
+		case autoscalingv2.ScalingActive:
+			problems = append(problems, fmt.Sprintf("hpa %s scaling inactive, metrics unavailable: %s", hpa.Name, condition.Reason))
+		case autoscalingv2.AbleToScale:
+			problems = append(problems, fmt.Sprintf("hpa %s unable to scale: %s", hpa.Name, condition.Reason))
+		}
+	}
+	return problems
+}
+
+func (wc *Watcher) WatchHorizontalPodAutoscalers(ctx context.Context, LabelSelector string) {
+	defer wc.Wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Str("caller", "watch_horizontal_pod_autoscalers").Msg("gracefully shutting down hpa watch")
+			return
+		default:
+			time.Sleep(15 * time.Second) // todo: param this timer
+			if err := wc.evaluateHorizontalPodAutoscalers(ctx, LabelSelector); err != nil {
+				log.Error().Str("caller", "watch_horizontal_pod_autoscalers").Msg(helpers.LogMsg("failed to list hpa: ", err.Error()))
+				continue
+			}
+			wc.markEvaluated("horizontalpodautoscalers")
+		}
+	}
+}
+
+// watchedScaleTargets returns the labelled deployments and statefulsets keyed like the autoscaler findings, eg: Deployment/default/nginx
+func (wc *Watcher) watchedScaleTargets(ctx context.Context, LabelSelector string) (map[string]bool, error) {
+	targets := make(map[string]bool)
+	deployments, err := wc.Clientset.AppsV1().Deployments(wc.Namespace).List(ctx, metav1.ListOptions{LabelSelector: LabelSelector})
+	if err != nil {
+		return nil, err
+	}
+	for _, deploy := range deployments.Items {
+		targets[fmt.Sprintf("Deployment/%s/%s", deploy.Namespace, deploy.Name)] = true
+	}
+	statefulsets, err := wc.Clientset.AppsV1().StatefulSets(wc.Namespace).List(ctx, metav1.ListOptions{LabelSelector: LabelSelector})
+	if err != nil {
+		return nil, err
+	}
+	for _, sts := range statefulsets.Items {
+		targets[fmt.Sprintf("StatefulSet/%s/%s", sts.Namespace, sts.Name)] = true
+	}
+	return targets, nil
+}
+
+// evaluateHorizontalPodAutoscalers refreshes the autoscaler findings applied to deployments and statefulsets, autoscalers
+// of workloads without the scrape label are left alone
+func (wc *Watcher) evaluateHorizontalPodAutoscalers(ctx context.Context, LabelSelector string) error {
+	targets, err := wc.watchedScaleTargets(ctx, LabelSelector)
+	if err != nil {
+		return err
+	}
+	hpas, err := wc.Clientset.AutoscalingV2().HorizontalPodAutoscalers(wc.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	findings := make(map[string]string)
+	seen := make(map[string]bool)
+	for i := range hpas.Items {
+		hpa := &hpas.Items[i]
+		target := fmt.Sprintf("%s/%s/%s", hpa.Spec.ScaleTargetRef.Kind, hpa.Namespace, hpa.Spec.ScaleTargetRef.Name)
+		if !targets[target] {
+			continue
+		}
+		seen[fmt.Sprintf("%s/%s", hpa.Namespace, hpa.Name)] = true
+		problems := wc.checkAutoscalerHealth(hpa)
+		if len(problems) == 0 {
+			continue
+		}
+		findings[target] = strings.Join(problems, "; ")
+		log.Warn().Str("caller", "watch_horizontal_pod_autoscalers").Str("tag", horizontalPodAutoscalers).Str("namespace", hpa.Namespace).Msg(helpers.LogMsg("hpa is not healthy: ", hpa.Name, ", ", findings[target]))
+	}
+	// forget saturation timers of autoscalers which no longer exist
+	for id := range wc.hpaSaturatedSince {
+		if !seen[id] {
+			delete(wc.hpaSaturatedSince, id)
+		}
+	}
+	wc.hpaMu.Lock()
+	wc.hpaFindings = findings
+	wc.hpaMu.Unlock()
+	return nil
+}
@@ -0,0 +1,78 @@
+package k8client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAutoscalerSaturation(t *testing.T) {
+	defer func(window time.Duration) { hpaSaturationWindow = window }(hpaSaturationWindow)
+	hpaSaturationWindow = 0
+	replicas := func(n int32) *int32 { return &n }
+	tests := []struct {
+		name      string
+		min       *int32
+		max       int32
+		current   int32
+		saturated bool
+	}{
+		{"below max", replicas(2), 10, 4, false},
+		{"at max", replicas(2), 10, 10, true},
+		{"pinned", replicas(3), 3, 3, false},
+		{"pinned by the default min", nil, 1, 1, false},
+		{"at max with the default min", nil, 3, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wc := &Watcher{hpaSaturatedSince: make(map[string]time.Time)}
+			hpa := &autoscalingv2.HorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api"},
+				Spec:       autoscalingv2.HorizontalPodAutoscalerSpec{MinReplicas: tt.min, MaxReplicas: tt.max},
+				Status:     autoscalingv2.HorizontalPodAutoscalerStatus{CurrentReplicas: tt.current},
+			}
+			if problems := wc.checkAutoscalerHealth(hpa); (len(problems) > 0) != tt.saturated {
+				t.Fatalf("problems %v, want saturated %v", problems, tt.saturated)
+			}
+		})
+	}
+}
+
+// only autoscalers of labelled workloads are evaluated, the others belong to workloads nobody asked to monitor
+func TestAutoscalerOfUnlabelledTargetIgnored(t *testing.T) {
+	defer func(window time.Duration) { hpaSaturationWindow = window }(hpaSaturationWindow)
+	hpaSaturationWindow = 0
+	saturated := func(name, target string) *autoscalingv2.HorizontalPodAutoscaler {
+		min := int32(1)
+		return &autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: name},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{MinReplicas: &min, MaxReplicas: 4,
+				ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: "Deployment", Name: target}},
+			Status: autoscalingv2.HorizontalPodAutoscalerStatus{CurrentReplicas: 4},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api", Labels: map[string]string{"k8sclustervitals.io/scrape": "true"}}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "batch"}},
+		saturated("api", "api"),
+		saturated("batch", "batch"),
+	)
+	wc := &Watcher{Clientset: client, hpaFindings: make(map[string]string), hpaSaturatedSince: make(map[string]time.Time)}
+	if err := wc.evaluateHorizontalPodAutoscalers(context.Background(), "k8sclustervitals.io/scrape=true"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := wc.autoscalerFinding("Deployment", "payments", "api"); !ok {
+		t.Fatal("saturated autoscaler of the labelled deployment not reported")
+	}
+	if reason, ok := wc.autoscalerFinding("Deployment", "payments", "batch"); ok {
+		t.Fatalf("autoscaler of the unlabelled deployment reported: %s", reason)
+	}
+	if _, ok := wc.hpaSaturatedSince["payments/batch"]; ok {
+		t.Fatal("saturation of the unlabelled deployment is tracked")
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -22,87 +23,181 @@ import (
 	"k8s.io/client-go/util/workqueue"
 )
 
-var scrapeConfig helpers.ScrapeConfiguration
-
-var (
-	kubeClientReady bool
-	readyMutex      sync.RWMutex
-)
-
 type Watcher struct {
-	Clientset  *kubernetes.Clientset
-	Queue      workqueue.RateLimitingInterface
-	CacheStore *helpers.KeyValueStore
-	Wg         sync.WaitGroup
+	ClusterName   string // empty unless running in multi-cluster mode or CLUSTER_NAME is set
+	Namespace     string // empty evaluates every namespace
+	Clientset     kubernetes.Interface
+	DynamicClient dynamic.Interface
+	Queue         workqueue.RateLimitingInterface
+	CacheStore    *helpers.KeyValueStore
+	Wg            sync.WaitGroup
+
+	hpaFindings       map[string]string    // autoscaler problems keyed by scale target
+	hpaSaturatedSince map[string]time.Time // first time an autoscaler was seen at maxReplicas
+	hpaMu             sync.RWMutex
+	pdbBlockedSince   map[string]time.Time          // first time a budget was seen allowing no disruptions
+	discovered        map[string]discoveredWorkload // references of scraped workloads keyed by status key, when AUTO_DISCOVER_REFERENCES is set
+	discoveredMu      sync.Mutex
+	scrapeConfig      helpers.ScrapeConfiguration
+	ready             bool            // guarded by registryMu
+	once              bool            // single evaluation pass, reports skip the hysteresis
+	pending           map[string]bool // watch loops yet to complete their first pass, guarded by registryMu
 }
 
 func (wc *Watcher) syncScrapeConfiguration(configMap *corev1.ConfigMap, reason string) {
 	if reason == "delete" {
-		wc.CacheStore.GoCacheDelete("watch.secrets.config")
-		wc.CacheStore.GoCacheDelete("watch.configmaps.config")
+		wc.CacheStore.GoCacheDelete(wc.configKey("watch.secrets.config"))
+		wc.CacheStore.GoCacheDelete(wc.configKey("watch.configmaps.config"))
+		wc.CacheStore.GoCacheDelete(wc.configKey("watch.customresources.config"))
+		wc.CacheStore.GoCacheDelete(wc.configKey("watch.expressions.config"))
+		wc.CacheStore.GoCacheDelete(wc.configKey("watch.groups.config"))
+		wc.CacheStore.GoCacheDelete(wc.configKey("watch.dependencies.config"))
 		return
 	}
 	// need this to refresh cache upon scrape configuration update
-	wc.CacheStore.GoCacheDelete("watch.secrets.config")
-	wc.CacheStore.GoCacheDelete("watch.configmaps.config")
+	wc.CacheStore.GoCacheDelete(wc.configKey("watch.secrets.config"))
+	wc.CacheStore.GoCacheDelete(wc.configKey("watch.configmaps.config"))
 
 	watched_secrets, ok := configMap.Data["watched-secrets"]
 	if !ok {
 		log.Info().Str("caller", "sync_scrape_configuration").Msg("watched-secrets not found in the scrape configuration")
-		wc.CacheStore.GoCacheDelete("watch.secrets.config")
+		wc.CacheStore.GoCacheDelete(wc.configKey("watch.secrets.config"))
 	} else {
-		yaml.Unmarshal([]byte(watched_secrets), &scrapeConfig.WatchedSecrets)
+		yaml.Unmarshal([]byte(watched_secrets), &wc.scrapeConfig.WatchedSecrets)
 		log.Info().Str("caller", "sync_scrape_configuration").Msg(helpers.LogMsg("watched-secrets set for event ", reason))
-		wc.CacheStore.GoCacheSet("watch.secrets.config", scrapeConfig.WatchedSecrets)
+		wc.CacheStore.GoCacheSet(wc.configKey("watch.secrets.config"), wc.scrapeConfig.WatchedSecrets)
+	}
+
+	wc.CacheStore.GoCacheDelete(wc.configKey("watch.customresources.config"))
+	watched_custom_resources, ok := configMap.Data["watched-custom-resources"]
+	if !ok {
+		log.Info().Str("caller", "sync_scrape_configuration").Msg("watched-custom-resources not found in the scrape configuration")
+	} else {
+		wc.scrapeConfig.WatchedCustomResources = nil
+		if err := yaml.Unmarshal([]byte(watched_custom_resources), &wc.scrapeConfig.WatchedCustomResources); err != nil {
+			log.Error().Str("caller", "sync_scrape_configuration").Msg(helpers.LogMsg("invalid watched-custom-resources: ", err.Error()))
+		} else {
+			log.Info().Str("caller", "sync_scrape_configuration").Msg(helpers.LogMsg("watched-custom-resources set for event ", reason))
+			wc.CacheStore.GoCacheSet(wc.configKey("watch.customresources.config"), wc.scrapeConfig.WatchedCustomResources)
+		}
+	}
+
+	wc.CacheStore.GoCacheDelete(wc.configKey("watch.expressions.config"))
+	health_expressions, ok := configMap.Data["health-expressions"]
+	if !ok {
+		log.Info().Str("caller", "sync_scrape_configuration").Msg("health-expressions not found in the scrape configuration")
+	} else {
+		wc.scrapeConfig.HealthExpressions = nil
+		if err := yaml.Unmarshal([]byte(health_expressions), &wc.scrapeConfig.HealthExpressions); err != nil {
+			log.Error().Str("caller", "sync_scrape_configuration").Msg(helpers.LogMsg("invalid health-expressions: ", err.Error()))
+		} else {
+			log.Info().Str("caller", "sync_scrape_configuration").Msg(helpers.LogMsg("health-expressions set for event ", reason))
+			wc.CacheStore.GoCacheSet(wc.configKey("watch.expressions.config"), wc.scrapeConfig.HealthExpressions)
+		}
+	}
+
+	wc.CacheStore.GoCacheDelete(wc.configKey("watch.groups.config"))
+	health_groups, ok := configMap.Data["health-groups"]
+	if !ok {
+		log.Info().Str("caller", "sync_scrape_configuration").Msg("health-groups not found in the scrape configuration")
+	} else {
+		wc.scrapeConfig.HealthGroups = nil
+		if err := yaml.Unmarshal([]byte(health_groups), &wc.scrapeConfig.HealthGroups); err != nil {
+			log.Error().Str("caller", "sync_scrape_configuration").Msg(helpers.LogMsg("invalid health-groups: ", err.Error()))
+		} else {
+			for name, group := range wc.scrapeConfig.HealthGroups {
+				if err := group.Validate(); err != nil {
+					// kept so that its endpoint reports not_ok with the reason instead of 404
+					log.Error().Str("caller", "sync_scrape_configuration").Msg(helpers.LogMsg("invalid health group ", name, ": ", err.Error()))
+				}
+			}
+			log.Info().Str("caller", "sync_scrape_configuration").Msg(helpers.LogMsg("health-groups set for event ", reason))
+			wc.CacheStore.GoCacheSet(wc.configKey("watch.groups.config"), wc.scrapeConfig.HealthGroups)
+		}
+	}
+
+	wc.CacheStore.GoCacheDelete(wc.configKey("watch.dependencies.config"))
+	dependencies, ok := configMap.Data["dependencies"]
+	if !ok {
+		log.Info().Str("caller", "sync_scrape_configuration").Msg("dependencies not found in the scrape configuration")
+	} else {
+		wc.scrapeConfig.Dependencies = nil
+		if err := yaml.Unmarshal([]byte(dependencies), &wc.scrapeConfig.Dependencies); err != nil {
+			log.Error().Str("caller", "sync_scrape_configuration").Msg(helpers.LogMsg("invalid dependencies: ", err.Error()))
+		} else {
+			log.Info().Str("caller", "sync_scrape_configuration").Msg(helpers.LogMsg("dependencies set for event ", reason))
+			wc.CacheStore.GoCacheSet(wc.configKey("watch.dependencies.config"), wc.scrapeConfig.Dependencies)
+		}
 	}
 
 	watched_configmaps, ok := configMap.Data["watched-configmaps"]
 	if !ok {
 		log.Info().Str("caller", "sync_scrape_configuration").Msg("watched-configmaps not found in the scrape configuration")
-		wc.CacheStore.GoCacheDelete("watch.secrets.config")
+		wc.CacheStore.GoCacheDelete(wc.configKey("watch.secrets.config"))
 	} else {
-		yaml.Unmarshal([]byte(watched_configmaps), &scrapeConfig.WatchedConfigMaps)
+		yaml.Unmarshal([]byte(watched_configmaps), &wc.scrapeConfig.WatchedConfigMaps)
 		log.Info().Str("caller", "sync_scrape_configuration").Msg(helpers.LogMsg("watched-configmap set for event ", reason))
-		wc.CacheStore.GoCacheSet("watch.configmaps.config", scrapeConfig.WatchedConfigMaps)
+		wc.CacheStore.GoCacheSet(wc.configKey("watch.configmaps.config"), wc.scrapeConfig.WatchedConfigMaps)
 	}
 }
 
-func NewKubeClient(cache *helpers.KeyValueStore) (*Watcher, error) {
+// kubeconfig is only consulted when ENV=kubeconfig
+var kubeconfig = flag.String("kubeconfig", defaultKubeconfig(), "(optional) absolute path to the kubeconfig file")
+
+func defaultKubeconfig() string {
+	if home := homeDir(); home != "" {
+		return filepath.Join(home, ".kube", "config")
+	}
+	return ""
+}
+
+// homeConfig builds the rest config of the cluster k8sClusterVitals runs against, based on ENV
+func homeConfig() (*rest.Config, error) {
 	env := os.Getenv("ENV")
-	var err error
-	var config *rest.Config
-	var kubeconfig *string
 	// Get the kubeconfig path from the user's home directory.
 	if env == "kubeconfig" {
 		log.Info().Str("func", "NewKubeClient").Msg("setting up k8client via ./kube/config ... starting....")
-		if home := homeDir(); home != "" {
-			kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-		} else {
-			kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-		}
-		flag.Parse()
-		config, err = clientcmd.BuildConfigFromFlags("", *kubeconfig)
-		if err != nil {
-			return nil, err
+		if !flag.Parsed() {
+			flag.Parse()
 		}
+		return clientcmd.BuildConfigFromFlags("", *kubeconfig)
 	} else if env == "inclusterconfig" {
 		log.Info().Str("func", "NewKubeClient").Msg("setting up k8client via service token ... starting....")
-		config, err = rest.InClusterConfig()
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		return nil, errors.New("invalid runtime")
+		return rest.InClusterConfig()
+	}
+	return nil, errors.New("invalid runtime")
+}
+
+func NewKubeClient(cache *helpers.KeyValueStore) (*Watcher, error) {
+	config, err := homeConfig()
+	if err != nil {
+		return nil, err
 	}
+	return newWatcher(os.Getenv("CLUSTER_NAME"), config, cache)
+}
+
+func newWatcher(clusterName string, config *rest.Config, cache *helpers.KeyValueStore) (*Watcher, error) {
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
 	watcher := &Watcher{
-		Clientset:  clientset,
-		Queue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
-		CacheStore: cache,
+		ClusterName:   clusterName,
+		Clientset:     clientset,
+		DynamicClient: dynamicClient,
+		Queue:         workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		CacheStore:    cache,
+
+		hpaFindings:       make(map[string]string),
+		hpaSaturatedSince: make(map[string]time.Time),
+		pdbBlockedSince:   make(map[string]time.Time),
+		discovered:        make(map[string]discoveredWorkload),
 	}
+	registerWatcher(watcher)
 	return watcher, nil
 }
 
@@ -111,10 +206,10 @@ func homeDir() string {
 	return os.Getenv("KUBE_HOME")
 }
 
-func setKubeClientReady(ready bool) {
-	readyMutex.Lock()
-	defer readyMutex.Unlock()
-	kubeClientReady = ready
+func (wc *Watcher) setKubeClientReady(ready bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	wc.ready = ready
 }
 
 // Periodically check if the Kubernetes client can list resources (e.g., pods)
@@ -128,20 +223,31 @@ func (wc *Watcher) CheckKubeClientHealth(ctx context.Context) {
 		default:
 			_, err := wc.Clientset.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
 			if err != nil {
-				setKubeClientReady(false)
+				wc.setKubeClientReady(false)
 			} else {
-				setKubeClientReady(true)
+				wc.setKubeClientReady(true)
 			}
 			time.Sleep(10 * time.Second)
 		}
 	}
 }
 
+// ReadinessProbe is true once the client of every monitored cluster can reach its api server,
+// followers are ready once they restored the leader snapshot
 func ReadinessProbe() bool {
-	readyMutex.RLock()
-	ready := kubeClientReady
-	readyMutex.RUnlock()
-	return ready
+	if Role() == RoleFollower {
+		return replicationHealthy()
+	}
+	readiness := ClusterReadiness()
+	if len(readiness) == 0 {
+		return false
+	}
+	for _, ready := range readiness {
+		if !ready {
+			return false
+		}
+	}
+	return true
 }
 
 // WatchScrapeConfig watches the config map for changes and updates the secret watcher
@@ -184,6 +290,9 @@ func (wc *Watcher) WatchScrapeConfig() {
 }
 
 func (wc *Watcher) StartWatchingResources(ctx context.Context, LabelSelector string) {
+	registryMu.Lock()
+	wc.pending = map[string]bool{"deployments": true, "statefulsets": true, "daemonsets": true, "horizontalpodautoscalers": true, "poddisruptionbudgets": true, "secrets": true, "configmaps": true, "customresources": true}
+	registryMu.Unlock()
 	go wc.WatchScrapeConfig()
 	wc.Wg.Add(1)
 	go wc.CheckKubeClientHealth(ctx)
@@ -192,7 +301,15 @@ func (wc *Watcher) StartWatchingResources(ctx context.Context, LabelSelector str
 	wc.Wg.Add(1)
 	go wc.WatchStatefulSet(ctx, LabelSelector)
 	wc.Wg.Add(1)
+	go wc.WatchDaemonSets(ctx, LabelSelector)
+	wc.Wg.Add(1)
+	go wc.WatchHorizontalPodAutoscalers(ctx, LabelSelector)
+	wc.Wg.Add(1)
+	go wc.WatchPodDisruptionBudgets(ctx, LabelSelector)
+	wc.Wg.Add(1)
 	go wc.WatchSecrets(ctx)
 	wc.Wg.Add(1)
 	go wc.WatchConfigMaps(ctx)
+	wc.Wg.Add(1)
+	go wc.WatchCustomResources(ctx)
 }
@@ -0,0 +1,108 @@
+package k8client
+
+import (
+	"context"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	eventComponent       = "k8sclustervitals"
+	eventReasonUnhealthy = "VitalsUnhealthy"
+	eventReasonRecovered = "VitalsRecovered"
+)
+
+// KubeEventsEnabled is true unless RECORD_EVENTS=false, health transitions are then recorded as kubernetes events
+// on the involved objects
+func KubeEventsEnabled() bool {
+	return os.Getenv("RECORD_EVENTS") != "false"
+}
+
+// RecordKubeEvents records a Warning VitalsUnhealthy or Normal VitalsRecovered event on the object of every state
+// change until ctx is cancelled, eg: Warning VitalsUnhealthy deployment api: 3/6 available. repeated events are
+// aggregated by the client-go correlator, silenced resources are skipped
+func RecordKubeEvents(ctx context.Context, watchers []*Watcher, cache *helpers.KeyValueStore) {
+	recorders := make(map[string]record.EventRecorder, len(watchers))
+	clusters := make(map[string]*Watcher, len(watchers))
+	for _, watcher := range watchers {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: watcher.Clientset.CoreV1().Events("")})
+		defer broadcaster.Shutdown()
+		recorders[watcher.ClusterName] = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent})
+		clusters[watcher.ClusterName] = watcher
+	}
+	events, _, _, cancel := cache.Subscribe(0)
+	defer func() { cancel() }()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// fell behind, the transitions missed are not recorded
+				log.Warn().Str("caller", "record_kube_events").Msg("event subscriber dropped, subscribing again")
+				cancel()
+				events, _, _, cancel = cache.Subscribe(0)
+				continue
+			}
+			t := event.Transition
+			recorder, ok := recorders[t.Cluster]
+			if !ok || cache.Silenced(t) {
+				continue
+			}
+			object := clusters[t.Cluster].involvedObject(t.Kind, t.Namespace, t.Name)
+			if object == nil {
+				continue // no event for custom resources and the other kinds
+			}
+			if t.To == helpers.StateHealthy {
+				recorder.Eventf(object, corev1.EventTypeNormal, eventReasonRecovered, "%s %s: healthy, was %s", t.Kind, t.Name, t.From)
+			} else {
+				recorder.Eventf(object, corev1.EventTypeWarning, eventReasonUnhealthy, "%s %s: %s", t.Kind, t.Name, t.Reason)
+			}
+		}
+	}
+}
+
+// involvedObject returns the object the event is recorded on, a reference without uid when it no longer exists
+// and nil for kinds without events
+func (wc *Watcher) involvedObject(kind string, namespace string, name string) runtime.Object {
+	var object runtime.Object
+	var err error
+	ref := &corev1.ObjectReference{Namespace: namespace, Name: name}
+	switch kind {
+	case deployments:
+		ref.APIVersion, ref.Kind = "apps/v1", "Deployment"
+		object, err = wc.Clientset.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	case statefulset:
+		ref.APIVersion, ref.Kind = "apps/v1", "StatefulSet"
+		object, err = wc.Clientset.AppsV1().StatefulSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	case daemonsets:
+		ref.APIVersion, ref.Kind = "apps/v1", "DaemonSet"
+		object, err = wc.Clientset.AppsV1().DaemonSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	case secrets:
+		ref.APIVersion, ref.Kind = "v1", "Secret"
+		object, err = wc.Clientset.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	case configmaps:
+		ref.APIVersion, ref.Kind = "v1", "ConfigMap"
+		object, err = wc.Clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	default:
+		return nil
+	}
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error().Str("caller", "record_kube_events").Msg(helpers.LogMsg("failed to get ", kind, " ", namespace, "/", name, ": ", err.Error()))
+		}
+		// eg: a missing secret, the event is still listed with its name
+		return ref
+	}
+	return object
+}
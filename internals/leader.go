@@ -0,0 +1,190 @@
+package k8client
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// replica roles reported on /readiness
+const (
+	RoleStandalone = "standalone"
+	RoleLeader     = "leader"
+	RoleFollower   = "follower"
+)
+
+// data keys of the snapshot ConfigMap, the plain json one is only read from snapshots of older releases
+const (
+	snapshotKey       = "status.json"
+	snapshotBinaryKey = "status.json.gz"
+)
+
+var (
+	role           = RoleStandalone
+	lastReplicated time.Time       // last time a follower restored the leader snapshot
+	leaderClusters map[string]bool // cluster readiness of the leader, served by followers which run no watchers
+	roleMu         sync.RWMutex
+)
+
+// LeaderElectionEnabled is true when LEADER_ELECTION=true, only the leader replica then runs the watchers
+func LeaderElectionEnabled() bool {
+	return os.Getenv("LEADER_ELECTION") == "true"
+}
+
+func Role() string {
+	roleMu.RLock()
+	defer roleMu.RUnlock()
+	return role
+}
+
+func setRole(r string) {
+	roleMu.Lock()
+	defer roleMu.Unlock()
+	role = r
+}
+
+// replicationHealthy is true when a follower restored the leader snapshot recently
+func replicationHealthy() bool {
+	roleMu.RLock()
+	defer roleMu.RUnlock()
+	return time.Since(lastReplicated) < time.Minute
+}
+
+// replicatedReadiness is the cluster readiness of the leader as of the last snapshot, a cluster is not ready once the
+// replication is stale as the follower cannot vouch for it anymore
+func replicatedReadiness() map[string]bool {
+	healthy := replicationHealthy()
+	roleMu.RLock()
+	defer roleMu.RUnlock()
+	readiness := make(map[string]bool, len(leaderClusters))
+	for name, ready := range leaderClusters {
+		readiness[name] = ready && healthy
+	}
+	return readiness
+}
+
+// replicate restores the leader snapshot into the local store
+func replicate(cache *helpers.KeyValueStore, snapshot helpers.Snapshot) error {
+	if err := cache.Restore(snapshot); err != nil {
+		return err
+	}
+	roleMu.Lock()
+	defer roleMu.Unlock()
+	lastReplicated = time.Now()
+	leaderClusters = snapshot.Clusters
+	return nil
+}
+
+func leaseName() string {
+	if name := os.Getenv("LEADER_ELECTION_LEASE"); name != "" {
+		return name
+	}
+	return "k8sclustervitals"
+}
+
+func leaseNamespace() string {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	return "k8cv"
+}
+
+func identity() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// RunLeaderElection campaigns for the lease until ctx is cancelled or the leadership is lost.
+// the leader runs lead and publishes its status records to a ConfigMap, followers restore that snapshot to serve the api.
+func RunLeaderElection(ctx context.Context, cache *helpers.KeyValueStore, lead func(ctx context.Context)) error {
+	config, err := homeConfig()
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: leaseName(), Namespace: leaseNamespace()},
+		Client:     client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity()},
+	}
+	setRole(RoleFollower)
+	followCtx, stopFollowing := context.WithCancel(ctx)
+	defer stopFollowing()
+	go followLeader(followCtx, client, cache)
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            leaseName(),
+		ReleaseOnCancel: true,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				log.Info().Str("caller", "leader_election").Msg(helpers.LogMsg("elected as leader: ", identity()))
+				stopFollowing()
+				setRole(RoleLeader)
+				go publishSnapshots(leaderCtx, client, cache)
+				lead(leaderCtx)
+			},
+			OnStoppedLeading: func() {
+				log.Info().Str("caller", "leader_election").Msg(helpers.LogMsg("leadership lost: ", identity()))
+			},
+			OnNewLeader: func(leader string) {
+				log.Info().Str("caller", "leader_election").Msg(helpers.LogMsg("current leader: ", leader))
+			},
+		},
+	})
+	return nil
+}
+
+// publishSnapshots writes the leader status records into the snapshot ConfigMap
+func publishSnapshots(ctx context.Context, client kubernetes.Interface, cache *helpers.KeyValueStore) {
+	backend := &ConfigMapBackend{Client: client, Namespace: leaseNamespace(), Name: helpers.LogMsg(leaseName(), "-snapshot")}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(15 * time.Second):
+			snapshot := cache.Snapshot()
+			snapshot.Clusters = ClusterReadiness()
+			if err := saveSnapshot(backend, snapshot); err != nil {
+				log.Error().Str("caller", "publish_snapshots").Msg(helpers.LogMsg("failed to publish status snapshot: ", err.Error()))
+			}
+		}
+	}
+}
+
+// followLeader restores the snapshot published by the leader into the local store
+func followLeader(ctx context.Context, client kubernetes.Interface, cache *helpers.KeyValueStore) {
+	backend := &ConfigMapBackend{Client: client, Namespace: leaseNamespace(), Name: helpers.LogMsg(leaseName(), "-snapshot")}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(15 * time.Second):
+			snapshot, err := backend.Load()
+			if err != nil {
+				log.Warn().Str("caller", "follow_leader").Msg(helpers.LogMsg("failed to read status snapshot: ", err.Error()))
+				continue
+			}
+			if err := replicate(cache, snapshot); err != nil {
+				log.Error().Str("caller", "follow_leader").Msg(helpers.LogMsg("failed to restore status snapshot: ", err.Error()))
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package k8client
+
+import (
+	"testing"
+	"time"
+
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// followers run no watchers, their own clients never turn ready and the leader readiness is served instead
+func TestFollowerServesLeaderReadiness(t *testing.T) {
+	registryMu.Lock()
+	previousRegistry := registry
+	registry = []*Watcher{{ClusterName: "prod"}, {ClusterName: "staging"}}
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = previousRegistry
+		registryMu.Unlock()
+		setRole(RoleStandalone)
+		roleMu.Lock()
+		lastReplicated, leaderClusters = time.Time{}, nil
+		roleMu.Unlock()
+	})
+	if ready := ClusterReadiness(); ready["prod"] || ready["staging"] {
+		t.Fatalf("readiness %v, the local clients did not connect", ready)
+	}
+
+	setRole(RoleFollower)
+	if err := replicate(helpers.NewKeyValueStore(), helpers.Snapshot{Clusters: map[string]bool{"prod": true, "staging": false}, TakenAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if ready := ClusterReadiness(); !ready["prod"] || ready["staging"] {
+		t.Fatalf("readiness %v, want the leader's: prod ready, staging not", ready)
+	}
+
+	// a stale replica cannot vouch for any cluster
+	roleMu.Lock()
+	lastReplicated = time.Now().Add(-2 * time.Minute)
+	roleMu.Unlock()
+	if ready := ClusterReadiness(); ready["prod"] {
+		t.Fatalf("readiness %v with a stale replica", ready)
+	}
+}
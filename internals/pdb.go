@@ -0,0 +1,154 @@
+package k8client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const podDisruptionBudgets = "poddisruptionbudget"
+
+// how long a budget may allow zero disruptions before it is reported, node drains hang meanwhile
+var pdbBlockedWindow = helpers.GetEnvDuration("PDB_BLOCKED_WINDOW", 15*time.Minute)
+
+// watchedTemplateLabels returns the pod template labels of every labelled deployment, statefulset and daemonset keyed by namespace
+func (wc *Watcher) watchedTemplateLabels(ctx context.Context, LabelSelector string) (map[string][]labels.Set, error) {
+	templates := make(map[string][]labels.Set)
+	deployments, err := wc.Clientset.AppsV1().Deployments(wc.Namespace).List(ctx, metav1.ListOptions{LabelSelector: LabelSelector})
+	if err != nil {
+		return nil, err
+	}
+	for _, deploy := range deployments.Items {
+		templates[deploy.Namespace] = append(templates[deploy.Namespace], labels.Set(deploy.Spec.Template.Labels))
+	}
+	statefulsets, err := wc.Clientset.AppsV1().StatefulSets(wc.Namespace).List(ctx, metav1.ListOptions{LabelSelector: LabelSelector})
+	if err != nil {
+		return nil, err
+	}
+	for _, sts := range statefulsets.Items {
+		templates[sts.Namespace] = append(templates[sts.Namespace], labels.Set(sts.Spec.Template.Labels))
+	}
+	daemonSets, err := wc.Clientset.AppsV1().DaemonSets(wc.Namespace).List(ctx, metav1.ListOptions{LabelSelector: LabelSelector})
+	if err != nil {
+		return nil, err
+	}
+	for _, ds := range daemonSets.Items {
+		templates[ds.Namespace] = append(templates[ds.Namespace], labels.Set(ds.Spec.Template.Labels))
+	}
+	return templates, nil
+}
+
+// checkDisruptionBudgetHealth evaluates a budget, the budget is only reported when it covers a labelled workload or is labelled itself
+func (wc *Watcher) checkDisruptionBudgetHealth(ctx context.Context, pdb *policyv1.PodDisruptionBudget, templates []labels.Set, labelled bool) {
+	key := fmt.Sprintf("poddisruptionbudgets.%s/%s", pdb.Namespace, pdb.Name)
+	id := fmt.Sprintf("%s/%s", pdb.Namespace, pdb.Name)
+	selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	if err != nil {
+		wc.report(key, helpers.StatusRecord{Kind: podDisruptionBudgets, Namespace: pdb.Namespace, Name: pdb.Name, State: helpers.StateInvalid, Reason: err.Error(), Labels: pdb.Labels})
+		return
+	}
+	covers := labelled
+	for _, template := range templates {
+		if !selector.Empty() && selector.Matches(template) {
+			covers = true
+			break
+		}
+	}
+	if !covers {
+		// the budget may have been reported while it still covered a labelled workload
+		delete(wc.pdbBlockedSince, id)
+		wc.forget(key)
+		return
+	}
+	record := helpers.StatusRecord{Kind: podDisruptionBudgets, Namespace: pdb.Namespace, Name: pdb.Name, State: helpers.StateHealthy, SilencedUntil: silenceUntil(pdb.Annotations), Labels: pdb.Labels}
+	pods, err := wc.Clientset.CoreV1().Pods(pdb.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		log.Error().Str("caller", "check_disruption_budget_health").Str("tag", podDisruptionBudgets).Str("namespace", pdb.Namespace).Msg(helpers.LogMsg("failed to list pods for pdb ", pdb.Name, ": ", err.Error()))
+		// the pods cannot be checked, do not hold on to a verdict like "selector matches no pods"
+		wc.report(key, record)
+		return
+	}
+	if len(pods.Items) == 0 {
+		record.State = helpers.StateInvalid
+		record.Reason = "selector matches no pods"
+		wc.report(key, record)
+		log.Error().Str("caller", "check_disruption_budget_health").Str("tag", podDisruptionBudgets).Str("namespace", pdb.Namespace).Msg(helpers.LogMsg("pdb selector matches no pods: ", pdb.Name))
+		return
+	}
+	var problems []string
+	if pdb.Status.CurrentHealthy < pdb.Status.DesiredHealthy {
+		problems = append(problems, fmt.Sprintf("%d/%d healthy pods", pdb.Status.CurrentHealthy, pdb.Status.DesiredHealthy))
+	}
+	if pdb.Status.DisruptionsAllowed == 0 {
+		since, ok := wc.pdbBlockedSince[id]
+		if !ok {
+			since = time.Now()
+			wc.pdbBlockedSince[id] = since
+		}
+		if blockedFor := time.Since(since); blockedFor >= pdbBlockedWindow {
+			problems = append(problems, fmt.Sprintf("no disruptions allowed for %s, node drains will hang", blockedFor.Round(time.Second)))
+		}
+	} else {
+		delete(wc.pdbBlockedSince, id)
+	}
+	if len(problems) > 0 {
+		record.State = helpers.StateDegraded
+		record.Reason = strings.Join(problems, "; ")
+		log.Warn().Str("caller", "check_disruption_budget_health").Str("tag", podDisruptionBudgets).Str("namespace", pdb.Namespace).Msg(helpers.LogMsg("pdb is not healthy: ", pdb.Name, ", ", record.Reason))
+	} else {
+		log.Info().Str("caller", "check_disruption_budget_health").Str("tag", podDisruptionBudgets).Str("namespace", pdb.Namespace).Msg(helpers.LogMsg("pdb is healthy: ", pdb.Name))
+	}
+	wc.report(key, record)
+}
+
+func (wc *Watcher) WatchPodDisruptionBudgets(ctx context.Context, LabelSelector string) {
+	defer wc.Wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Str("caller", "watch_pod_disruption_budgets").Msg("gracefully shutting down pdb watch")
+			return
+		default:
+			time.Sleep(15 * time.Second) // todo: param this timer
+			if err := wc.evaluateDisruptionBudgets(ctx, LabelSelector); err != nil {
+				log.Error().Str("caller", "watch_pod_disruption_budgets").Msg(helpers.LogMsg("failed to evaluate pdb: ", err.Error()))
+				continue
+			}
+			wc.markEvaluated("poddisruptionbudgets")
+		}
+	}
+}
+
+// evaluateDisruptionBudgets runs a single evaluation pass over the budgets covering labelled workloads
+func (wc *Watcher) evaluateDisruptionBudgets(ctx context.Context, LabelSelector string) error {
+	scrapeSelector, _ := labels.Parse(LabelSelector)
+	templates, err := wc.watchedTemplateLabels(ctx, LabelSelector)
+	if err != nil {
+		return fmt.Errorf("failed to list labelled workloads: %w", err)
+	}
+	pdbs, err := wc.Clientset.PolicyV1().PodDisruptionBudgets(wc.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list pdb: %w", err)
+	}
+	seen := make(map[string]bool)
+	for i := range pdbs.Items {
+		pdb := &pdbs.Items[i]
+		seen[fmt.Sprintf("%s/%s", pdb.Namespace, pdb.Name)] = true
+		wc.checkDisruptionBudgetHealth(ctx, pdb, templates[pdb.Namespace], scrapeSelector.Matches(labels.Set(pdb.Labels)))
+	}
+	// forget blocked timers of budgets which no longer exist
+	for id := range wc.pdbBlockedSince {
+		if !seen[id] {
+			delete(wc.pdbBlockedSince, id)
+		}
+	}
+	return nil
+}
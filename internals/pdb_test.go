@@ -0,0 +1,36 @@
+package k8client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// a budget which stops covering a labelled workload does not keep its last verdict
+func TestUncoveredDisruptionBudgetIsForgotten(t *testing.T) {
+	wc := &Watcher{CacheStore: helpers.NewKeyValueStore(), pdbBlockedSince: make(map[string]time.Time)}
+	key := "poddisruptionbudgets.payments/api"
+	wc.report(key, helpers.StatusRecord{Kind: podDisruptionBudgets, Namespace: "payments", Name: "api", State: helpers.StateInvalid, Reason: "selector matches no pods"})
+	if n := wc.CacheStore.LenAll(); n != 1 {
+		t.Fatalf("%d records reported, want 1", n)
+	}
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}},
+	}
+	wc.pdbBlockedSince["payments/api"] = time.Now()
+	wc.checkDisruptionBudgetHealth(context.Background(), pdb, nil, false)
+	if n := wc.CacheStore.LenAll(); n != 0 {
+		t.Fatalf("%d records left, want the uncovered budget cleared", n)
+	}
+	if len(wc.CacheStore.Inventory(helpers.StatusFilter{})) != 0 {
+		t.Fatal("uncovered budget is still monitored")
+	}
+	if _, ok := wc.pdbBlockedSince["payments/api"]; ok {
+		t.Fatal("blocked timer kept")
+	}
+}
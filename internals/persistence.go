@@ -0,0 +1,249 @@
+package k8client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the api server rejects objects above 1MiB, leave room for the metadata of the ConfigMap
+var configMapSnapshotLimit = 1000 * 1024
+
+// ConfigMapBackend keeps the snapshot as gzipped json in a ConfigMap
+type ConfigMapBackend struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+}
+
+// Save writes the snapshot, the history is trimmed to the newest transitions of every resource until it fits
+func (b *ConfigMapBackend) Save(snapshot helpers.Snapshot) error {
+	data, err := fitSnapshot(snapshot, configMapSnapshotLimit)
+	if err != nil {
+		return err
+	}
+	configMaps := b.Client.CoreV1().ConfigMaps(b.Namespace)
+	cm, err := configMaps.Get(context.TODO(), b.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = configMaps.Create(context.TODO(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: b.Name, Namespace: b.Namespace},
+			BinaryData: map[string][]byte{snapshotBinaryKey: data},
+		}, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+	cm.Data, cm.BinaryData = nil, map[string][]byte{snapshotBinaryKey: data}
+	_, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+	return err
+}
+
+func (b *ConfigMapBackend) Load() (helpers.Snapshot, error) {
+	var snapshot helpers.Snapshot
+	cm, err := b.Client.CoreV1().ConfigMaps(b.Namespace).Get(context.TODO(), b.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return snapshot, helpers.ErrNoSnapshot
+	} else if err != nil {
+		return snapshot, err
+	}
+	if compressed, ok := cm.BinaryData[snapshotBinaryKey]; ok {
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return snapshot, err
+		}
+		defer reader.Close()
+		err = json.NewDecoder(reader).Decode(&snapshot)
+		return snapshot, err
+	}
+	// written by an older release
+	data, ok := cm.Data[snapshotKey]
+	if !ok {
+		return snapshot, helpers.ErrNoSnapshot
+	}
+	err = json.Unmarshal([]byte(data), &snapshot)
+	return snapshot, err
+}
+
+// fitSnapshot encodes the snapshot within limit bytes, halving the transitions kept per resource until it fits
+func fitSnapshot(snapshot helpers.Snapshot, limit int) ([]byte, error) {
+	kept := 0
+	for _, transitions := range snapshot.History {
+		if len(transitions) > kept {
+			kept = len(transitions)
+		}
+	}
+	trimmed := snapshot
+	for {
+		data, err := encodeSnapshot(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) <= limit {
+			recordSnapshotSize(len(data), trimmed.HistoryLimit)
+			if trimmed.HistoryLimit > 0 {
+				log.Warn().Str("caller", "fit_snapshot").Msg(helpers.LogMsg("snapshot history trimmed to the last ", strconv.Itoa(trimmed.HistoryLimit), " transitions per resource to fit the ConfigMap"))
+			}
+			return data, nil
+		}
+		if kept <= 1 {
+			return nil, fmt.Errorf("snapshot of %d bytes exceeds the %d bytes a ConfigMap can hold", len(data), limit)
+		}
+		kept /= 2
+		trimmed = snapshot.WithHistoryLimit(kept)
+	}
+}
+
+func encodeSnapshot(snapshot helpers.Snapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(writer).Encode(snapshot); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// snapshot saves of this replica, surfaced on /readiness and /metrics so that a failing backend does not go unnoticed
+var (
+	snapshotMu           sync.Mutex
+	snapshotSaving       bool      // this replica persists or publishes snapshots
+	snapshotErr          error     // of the last save
+	snapshotFailures     int       // since startup
+	snapshotSavedAt      time.Time // last successful save
+	snapshotBytes        int       // encoded size of the last snapshot written to a ConfigMap
+	snapshotHistoryLimit int       // transitions per resource kept by the last ConfigMap snapshot, 0 when complete
+)
+
+func recordSnapshotSize(size, historyLimit int) {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	snapshotBytes, snapshotHistoryLimit = size, historyLimit
+}
+
+// saveSnapshot saves a snapshot of the store and records the outcome
+func saveSnapshot(backend helpers.SnapshotBackend, snapshot helpers.Snapshot) error {
+	err := backend.Save(snapshot)
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	snapshotSaving, snapshotErr = true, err
+	if err != nil {
+		snapshotFailures++
+	} else {
+		snapshotSavedAt = time.Now()
+	}
+	return err
+}
+
+// SnapshotError returns the error of the last snapshot save, nil when it succeeded or the replica saves none
+func SnapshotError() error {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	return snapshotErr
+}
+
+// WriteSnapshotMetrics renders the snapshot saves in the prometheus text exposition format
+func WriteSnapshotMetrics(w io.Writer) {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	if !snapshotSaving {
+		return
+	}
+	fmt.Fprintln(w, "# HELP k8sclustervitals_snapshot_save_failures_total Snapshot saves which failed since startup.")
+	fmt.Fprintln(w, "# TYPE k8sclustervitals_snapshot_save_failures_total counter")
+	fmt.Fprintf(w, "k8sclustervitals_snapshot_save_failures_total %d\n", snapshotFailures)
+	fmt.Fprintln(w, "# HELP k8sclustervitals_snapshot_last_success_timestamp_seconds Time of the last successful snapshot save.")
+	fmt.Fprintln(w, "# TYPE k8sclustervitals_snapshot_last_success_timestamp_seconds gauge")
+	lastSuccess := 0.0
+	if !snapshotSavedAt.IsZero() {
+		lastSuccess = float64(snapshotSavedAt.Unix())
+	}
+	fmt.Fprintf(w, "k8sclustervitals_snapshot_last_success_timestamp_seconds %g\n", lastSuccess)
+	if snapshotBytes > 0 {
+		fmt.Fprintln(w, "# HELP k8sclustervitals_snapshot_bytes Compressed size of the last snapshot written to a ConfigMap.")
+		fmt.Fprintln(w, "# TYPE k8sclustervitals_snapshot_bytes gauge")
+		fmt.Fprintf(w, "k8sclustervitals_snapshot_bytes %d\n", snapshotBytes)
+		fmt.Fprintln(w, "# HELP k8sclustervitals_snapshot_history_limit Transitions per resource kept by the last ConfigMap snapshot, 0 when the history is complete.")
+		fmt.Fprintln(w, "# TYPE k8sclustervitals_snapshot_history_limit gauge")
+		fmt.Fprintf(w, "k8sclustervitals_snapshot_history_limit %d\n", snapshotHistoryLimit)
+	}
+}
+
+// NewSnapshotBackend returns the backend selected by PERSISTENCE_BACKEND (bolt or configmap), nil when persistence is disabled
+func NewSnapshotBackend() (helpers.SnapshotBackend, error) {
+	switch os.Getenv("PERSISTENCE_BACKEND") {
+	case "":
+		return nil, nil
+	case "bolt":
+		path := os.Getenv("PERSISTENCE_PATH")
+		if path == "" {
+			path = "/var/lib/k8sclustervitals/vitals.db"
+		}
+		return helpers.NewBoltBackend(path)
+	case "configmap":
+		config, err := homeConfig()
+		if err != nil {
+			return nil, err
+		}
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		name := os.Getenv("PERSISTENCE_CONFIGMAP")
+		if name == "" {
+			name = "k8sclustervitals-state"
+		}
+		return &ConfigMapBackend{Client: client, Namespace: leaseNamespace(), Name: name}, nil
+	}
+	return nil, fmt.Errorf("unknown persistence backend %s", os.Getenv("PERSISTENCE_BACKEND"))
+}
+
+// RestoreSnapshot loads the last snapshot into the store
+func RestoreSnapshot(backend helpers.SnapshotBackend, cache *helpers.KeyValueStore) {
+	snapshot, err := backend.Load()
+	if err == helpers.ErrNoSnapshot {
+		log.Info().Str("caller", "restore_snapshot").Msg("no snapshot to restore")
+		return
+	} else if err != nil {
+		log.Error().Str("caller", "restore_snapshot").Msg(helpers.LogMsg("failed to load snapshot: ", err.Error()))
+		return
+	}
+	if err := cache.Restore(snapshot); err != nil {
+		log.Error().Str("caller", "restore_snapshot").Msg(helpers.LogMsg("failed to restore snapshot: ", err.Error()))
+		return
+	}
+	log.Info().Str("caller", "restore_snapshot").Msg(helpers.LogMsg("restored snapshot taken at ", snapshot.TakenAt.Format(time.RFC3339)))
+}
+
+// PersistSnapshots saves a snapshot of the store every 15 seconds and once more on shutdown
+func PersistSnapshots(ctx context.Context, backend helpers.SnapshotBackend, cache *helpers.KeyValueStore) {
+	for {
+		select {
+		case <-ctx.Done():
+			if err := saveSnapshot(backend, cache.Snapshot()); err != nil {
+				log.Error().Str("caller", "persist_snapshots").Msg(helpers.LogMsg("failed to save final snapshot: ", err.Error()))
+			}
+			return
+		case <-time.After(15 * time.Second):
+			if err := saveSnapshot(backend, cache.Snapshot()); err != nil {
+				log.Error().Str("caller", "persist_snapshots").Msg(helpers.LogMsg("failed to save snapshot: ", err.Error()))
+			}
+		}
+	}
+}
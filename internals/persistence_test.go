@@ -0,0 +1,110 @@
+package k8client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func largeSnapshot() helpers.Snapshot {
+	snapshot := helpers.Snapshot{History: map[string][]helpers.Transition{}, TakenAt: time.Now()}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for r := 0; r < 20; r++ {
+		key := fmt.Sprintf("deployments.apps/default/app-%d", r)
+		for i := 0; i < 100; i++ {
+			snapshot.History[key] = append(snapshot.History[key], helpers.Transition{
+				Key: key, Kind: "deployments", Namespace: "default", Name: fmt.Sprintf("app-%d", r),
+				From: "available", To: "unavailable",
+				Reason:    fmt.Sprintf("replica %x of %x not ready", i*7919+r, r*104729+i),
+				Timestamp: start.Add(time.Duration(i) * time.Minute),
+			})
+		}
+	}
+	return snapshot
+}
+
+func TestConfigMapSnapshotTrimmedToFit(t *testing.T) {
+	previous := configMapSnapshotLimit
+	configMapSnapshotLimit = 8 * 1024
+	t.Cleanup(func() {
+		configMapSnapshotLimit = previous
+		snapshotMu.Lock()
+		snapshotSaving, snapshotErr, snapshotBytes, snapshotHistoryLimit = false, nil, 0, 0
+		snapshotMu.Unlock()
+	})
+
+	backend := &ConfigMapBackend{Client: fake.NewSimpleClientset(), Namespace: "vitals", Name: "vitals-snapshot"}
+	if err := saveSnapshot(backend, largeSnapshot()); err != nil {
+		t.Fatal(err)
+	}
+	if err := SnapshotError(); err != nil {
+		t.Fatalf("snapshot error %v after a successful save", err)
+	}
+	cm, err := backend.Client.CoreV1().ConfigMaps("vitals").Get(context.TODO(), "vitals-snapshot", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := len(cm.BinaryData[snapshotBinaryKey]); size == 0 || size > configMapSnapshotLimit {
+		t.Fatalf("stored %d bytes, want at most %d", size, configMapSnapshotLimit)
+	}
+
+	restored, err := backend.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.HistoryLimit == 0 || restored.HistoryLimit >= 100 {
+		t.Fatalf("history limit %d, want the history trimmed", restored.HistoryLimit)
+	}
+	transitions := restored.History["deployments.apps/default/app-0"]
+	if len(transitions) != restored.HistoryLimit {
+		t.Fatalf("%d transitions restored, want %d", len(transitions), restored.HistoryLimit)
+	}
+	// the newest transitions are kept
+	if last := transitions[len(transitions)-1]; !last.Timestamp.Equal(time.Date(2026, 1, 1, 1, 39, 0, 0, time.UTC)) {
+		t.Fatalf("last transition at %v, want the newest one", last.Timestamp)
+	}
+}
+
+func TestConfigMapSnapshotTooLarge(t *testing.T) {
+	previous := configMapSnapshotLimit
+	configMapSnapshotLimit = 64
+	t.Cleanup(func() {
+		configMapSnapshotLimit = previous
+		snapshotMu.Lock()
+		snapshotSaving, snapshotErr = false, nil
+		snapshotMu.Unlock()
+	})
+
+	client := fake.NewSimpleClientset()
+	backend := &ConfigMapBackend{Client: client, Namespace: "vitals", Name: "vitals-snapshot"}
+	if err := saveSnapshot(backend, largeSnapshot()); err == nil {
+		t.Fatal("saved a snapshot larger than the ConfigMap limit")
+	}
+	if SnapshotError() == nil {
+		t.Fatal("the failed save is not surfaced")
+	}
+	if _, err := backend.Load(); err != helpers.ErrNoSnapshot {
+		t.Fatalf("load error %v, want nothing written", err)
+	}
+}
+
+func TestConfigMapSnapshotLoadsPlainJSON(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "vitals-snapshot", Namespace: "vitals"},
+		Data:       map[string]string{snapshotKey: `{"status":{"secrets.default/tls":{"status":"available"}},"takenAt":"2026-01-01T00:00:00Z"}`},
+	})
+	backend := &ConfigMapBackend{Client: client, Namespace: "vitals", Name: "vitals-snapshot"}
+	snapshot, err := backend.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := snapshot.Status["secrets.default/tls"]; !ok {
+		t.Fatalf("status %v, want the record of the older snapshot", snapshot.Status)
+	}
+}
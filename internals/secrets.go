@@ -11,6 +11,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const secrets = "secret"
+
 func (wc *Watcher) WatchSecrets(ctx context.Context) {
 	defer wc.Wg.Done()
 	for {
@@ -20,26 +22,45 @@ func (wc *Watcher) WatchSecrets(ctx context.Context) {
 			return
 		default:
 			time.Sleep(15 * time.Second) // todo param this timer
-			data, err := wc.CacheStore.GoCacheGet("watch.secrets.config")
-			if err != nil {
-				log.Warn().Str("caller", "watch_secrets").Msg("watch.secrets.config cachehit doesnt exists")
-				return
-			}
-			v := data.([]helpers.WatchedResource)
-			// todo: move to go routine and make sure its completes
-			for _, secretMetadata := range v {
-				_, err := wc.Clientset.CoreV1().Secrets(secretMetadata.Namespace).Get(context.TODO(), secretMetadata.Name, metav1.GetOptions{})
-				if errors.IsNotFound(err) {
-					wc.CacheStore.Set(fmt.Sprintf("secrets.%s/%s", secretMetadata.Namespace, secretMetadata.Name), []byte("unavailable"))
-					log.Info().Str("caller", "watch_secrets").Msg(helpers.LogMsg("Secret not found in namespace ", secretMetadata.Name, " namespace: ", secretMetadata.Namespace))
-				} else if err != nil {
-					wc.CacheStore.Set(fmt.Sprintf("secrets.%s/%s", secretMetadata.Namespace, secretMetadata.Name), []byte("invalid"))
-					log.Error().Str("caller", "watch_secrets").Msg(helpers.LogMsg("error retrieving the secrets ", secretMetadata.Namespace, "-", secretMetadata.Name))
-				} else {
-					wc.CacheStore.Delete(fmt.Sprintf("secrets.%s/%s", secretMetadata.Namespace, secretMetadata.Name))
-					log.Info().Str("caller", "watch_secrets").Msg(helpers.LogMsg("Secret found in namespace ", secretMetadata.Name, " namespace: ", secretMetadata.Namespace))
-				}
-			}
+			wc.evaluateSecrets(ctx)
+			wc.markEvaluated("secrets")
+		}
+	}
+}
+
+// evaluateSecrets runs a single evaluation pass over the watched and discovered secrets
+func (wc *Watcher) evaluateSecrets(ctx context.Context) {
+	v := wc.watchedResources(secrets, "watch.secrets.config")
+	if len(v) == 0 {
+		log.Warn().Str("caller", "watch_secrets").Msg("watch.secrets.config cachehit doesnt exists")
+		// nothing to watch until a scrape configuration shows up, keep polling for it
+		return
+	}
+	// todo: move to go routine and make sure its completes
+	for _, secretMetadata := range v {
+		key := fmt.Sprintf("secrets.%s/%s", secretMetadata.Namespace, secretMetadata.Name)
+		// a missing secret keeps the labels and the silence-until annotation it was last seen with, so labelSelector
+		// queries still match it and deleting it during a maintenance window stays silenced
+		known, _ := wc.CacheStore.LastObserved(wc.statusKey(key))
+		record := helpers.StatusRecord{Kind: secrets, Namespace: secretMetadata.Namespace, Name: secretMetadata.Name, Labels: known.Labels, SilencedUntil: known.SilencedUntil}
+		found, err := wc.Clientset.CoreV1().Secrets(secretMetadata.Namespace).Get(ctx, secretMetadata.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) && secretMetadata.Optional {
+			record.State = helpers.StateHealthy
+			log.Info().Str("caller", "watch_secrets").Msg(helpers.LogMsg("optional Secret not found in namespace ", secretMetadata.Name, " namespace: ", secretMetadata.Namespace))
+		} else if errors.IsNotFound(err) {
+			record.State = helpers.StateUnavailable
+			record.Reason = "not found"
+			log.Info().Str("caller", "watch_secrets").Msg(helpers.LogMsg("Secret not found in namespace ", secretMetadata.Name, " namespace: ", secretMetadata.Namespace))
+		} else if err != nil {
+			record.State = helpers.StateInvalid
+			record.Reason = err.Error()
+			log.Error().Str("caller", "watch_secrets").Msg(helpers.LogMsg("error retrieving the secrets ", secretMetadata.Namespace, "-", secretMetadata.Name))
+		} else {
+			record.State = helpers.StateHealthy
+			record.Labels = found.Labels
+			record.SilencedUntil = silenceUntil(found.Annotations)
+			log.Info().Str("caller", "watch_secrets").Msg(helpers.LogMsg("Secret found in namespace ", secretMetadata.Name, " namespace: ", secretMetadata.Namespace))
 		}
+		wc.report(key, record)
 	}
 }
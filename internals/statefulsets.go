@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -15,18 +16,28 @@ import (
 
 const statefulset = "statefulset"
 
-func (wc *Watcher) checkStatefulsetHealth(statefulSet *v1.StatefulSet, initialDelaySeconds int16) {
+func (wc *Watcher) checkStatefulsetHealth(ctx context.Context, statefulSet *v1.StatefulSet, initialDelaySeconds int16) {
 	desiredReplicas := *statefulSet.Spec.Replicas
 	time.Sleep(time.Duration(initialDelaySeconds) * time.Second)
-	if statefulSet.Status.ReadyReplicas == desiredReplicas && statefulSet.Status.CurrentReplicas == desiredReplicas {
+	record := helpers.StatusRecord{Kind: statefulset, Namespace: statefulSet.Namespace, Name: statefulSet.Name, State: helpers.StateHealthy, SLOTarget: sloTarget(statefulSet.Annotations), SilencedUntil: silenceUntil(statefulSet.Annotations), Labels: statefulSet.Labels,
+		DependsOn: inferredDependencies(statefulSet.Namespace, &statefulSet.Spec.Template.Spec), Replicas: &helpers.Replicas{Ready: statefulSet.Status.ReadyReplicas, Desired: desiredReplicas}}
+	if expression := wc.healthExpression(statefulset, statefulSet.Annotations); expression != "" {
+		record.State, record.Reason = wc.evaluateHealthExpression(ctx, expression, statefulSet, statefulSet.Namespace, statefulSet.Spec.Selector)
+		log.Info().Str("caller", "check_statefulset_health").Str("tag", statefulset).Str("namespace", statefulSet.Namespace).Msg(helpers.LogMsg("statefulset health expression evaluated: ", statefulSet.Name, ", state: ", record.State))
+	} else if statefulSet.Status.ReadyReplicas == desiredReplicas && statefulSet.Status.CurrentReplicas == desiredReplicas {
 		log.Info().Str("caller", "check_statefulset_health").Str("tag", statefulset).Str("namespace", statefulSet.Namespace).Msg(helpers.LogMsg("statefulset is healthy: ", statefulSet.Name))
-		wc.CacheStore.Delete(fmt.Sprintf("statefulset.apps/%s", statefulSet.Name))
 	} else {
 		UnavailableReplicas := desiredReplicas - statefulSet.Status.CurrentReplicas
-		// todo: to reduce some work on cache, check for key existance first and set the cache
-		wc.CacheStore.Set(fmt.Sprintf("statefulset.apps/%s", statefulSet.Name), []byte("unavailable"))
+		record.State = helpers.StateUnavailable
+		record.Reason = fmt.Sprintf("%d/%d ready", statefulSet.Status.ReadyReplicas, desiredReplicas)
 		log.Error().Str("caller", "check_statefulset_health").Str("tag", statefulset).Str("namespace", statefulSet.Namespace).Msg(helpers.LogMsg("statefulset is not healthy: ", statefulSet.Name, ", unavailable: ", strconv.FormatInt(int64(UnavailableReplicas), 10)))
 	}
+	if reason, ok := wc.applyAutoscalerFinding(&record, "StatefulSet", statefulSet.Namespace, statefulSet.Name); ok {
+		log.Warn().Str("caller", "check_statefulset_health").Str("tag", statefulset).Str("namespace", statefulSet.Namespace).Msg(helpers.LogMsg("statefulset autoscaler is not healthy: ", statefulSet.Name, ", ", reason))
+	}
+	wc.discoverReferences(wc.statusKey(fmt.Sprintf("statefulset.apps/%s/%s", statefulSet.Namespace, statefulSet.Name)), statefulSet.Namespace, &statefulSet.Spec.Template.Spec)
+	// todo: to reduce some work on cache, check for key existance first and set the cache
+	wc.report(fmt.Sprintf("statefulset.apps/%s/%s", statefulSet.Namespace, statefulSet.Name), record)
 }
 
 func (wc *Watcher) WatchStatefulSet(ctx context.Context, LabelSelector string) {
@@ -38,18 +49,31 @@ func (wc *Watcher) WatchStatefulSet(ctx context.Context, LabelSelector string) {
 			return
 		default:
 			time.Sleep(15 * time.Second) // todo param this timer
-			statefulsets, err := wc.Clientset.AppsV1().StatefulSets("").List(context.TODO(), metav1.ListOptions{
-				LabelSelector: LabelSelector, // Use LabelSelector to filter statefulset by annotations
-			})
-			if err != nil {
+			if err := wc.evaluateStatefulSets(ctx, LabelSelector); err != nil {
 				log.Info().Str("caller", "watch_statefulsets").Msg("no statefulset has been found...")
 				continue
 			}
-			for _, statefulset := range statefulsets.Items {
-				go func(sts v1.StatefulSet) {
-					wc.checkStatefulsetHealth(&sts, 3)
-				}(statefulset)
-			}
+			wc.markEvaluated("statefulsets")
 		}
 	}
 }
+
+// evaluateStatefulSets runs a single evaluation pass over the labelled statefulsets
+func (wc *Watcher) evaluateStatefulSets(ctx context.Context, LabelSelector string) error {
+	statefulsets, err := wc.Clientset.AppsV1().StatefulSets(wc.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: LabelSelector, // Use LabelSelector to filter statefulset by annotations
+	})
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for _, statefulset := range statefulsets.Items {
+		wg.Add(1)
+		go func(sts v1.StatefulSet) {
+			defer wg.Done()
+			wc.checkStatefulsetHealth(ctx, &sts, wc.initialDelaySeconds())
+		}(statefulset)
+	}
+	wg.Wait()
+	return nil
+}
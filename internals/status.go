@@ -0,0 +1,69 @@
+package k8client
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// report records the evaluated state of a resource against its status key, healthy resources are removed from the store
+// once the hysteresis agrees
+func (wc *Watcher) report(key string, record helpers.StatusRecord) {
+	key = wc.statusKey(key)
+	record.Cluster = wc.ClusterName
+	record.DependsOn = dedupe(append(record.DependsOn, wc.configuredDependencies(record)...))
+	wc.CacheStore.Observe(key, record)
+	record.CheckedAt = time.Now()
+	report := wc.CacheStore.Report
+	if wc.once {
+		// a single pass cannot wait for consecutive evaluations
+		report = func(key string, record helpers.StatusRecord) error {
+			if record.State == helpers.StateHealthy {
+				return wc.CacheStore.Delete(key)
+			}
+			return wc.CacheStore.SetStatus(key, record)
+		}
+	}
+	if err := report(key, record); err != nil {
+		log.Error().Str("caller", "report").Msg(helpers.LogMsg("failed to store status for ", key, ": ", err.Error()))
+	}
+}
+
+// forget clears the status key of a resource which is no longer in scope
+func (wc *Watcher) forget(key string) {
+	wc.CacheStore.Forget(wc.statusKey(key))
+}
+
+// availability target of a resource in percent, eg: k8sclustervitals.io/slo: "99.9"
+const sloAnnotation = "k8sclustervitals.io/slo"
+
+func sloTarget(annotations map[string]string) float64 {
+	v, ok := annotations[sloAnnotation]
+	if !ok {
+		return 0
+	}
+	target, err := strconv.ParseFloat(v, 64)
+	if err != nil || target <= 0 || target >= 100 {
+		log.Warn().Str("caller", "slo_target").Msg(helpers.LogMsg("ignoring invalid slo annotation ", v))
+		return 0
+	}
+	return target
+}
+
+// mutes a resource until the given RFC3339 time, eg: k8sclustervitals.io/silence-until: "2024-10-01T06:00:00Z"
+const silenceAnnotation = "k8sclustervitals.io/silence-until"
+
+func silenceUntil(annotations map[string]string) *time.Time {
+	v, ok := annotations[silenceAnnotation]
+	if !ok {
+		return nil
+	}
+	until, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		log.Warn().Str("caller", "silence_until").Msg(helpers.LogMsg("ignoring invalid silence-until annotation ", v))
+		return nil
+	}
+	return &until
+}
@@ -5,11 +5,15 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/vivekganesan01/k8sClusterVitals/cli"
+	"github.com/vivekganesan01/k8sClusterVitals/dashboard"
 	k8client "github.com/vivekganesan01/k8sClusterVitals/internals"
 	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
 )
@@ -19,6 +23,9 @@ var cacheStore *helpers.KeyValueStore
 const LabelSelector = "k8sclustervitals.io/scrape=true"
 
 func init() {
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		return // the cli sets up its own logging and store
+	}
 	log.Info().Str("caller", "main.go").Msg("Welcome to k8sClusterVitals ... starting....")
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	log.Info().Str("caller", "main.go").Msg("initialising cache server....")
@@ -26,16 +33,43 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel() // Ensure the context is cancelled when the main function exits
-	go httpServer(ctx)
-	watcher, err := k8client.NewKubeClient(cacheStore)
+	backend, err := k8client.NewSnapshotBackend()
+	if err != nil {
+		log.Error().Str("caller", "main.go").Msg(helpers.LogMsg("failed to create persistence backend: ", err.Error()))
+	} else if backend != nil {
+		k8client.RestoreSnapshot(backend, cacheStore)
+	}
+	var certs *certReloader // nil serves plain http and grpc
+	if tlsEnabled() {
+		settings, err := loadTLSSettings()
+		if err == nil {
+			certs, err = newCertReloader(settings)
+		}
+		if err != nil {
+			log.Fatal().Str("caller", "main.go").Msg(helpers.LogMsg("invalid tls configuration: ", err.Error()))
+		}
+		go certs.watch(ctx)
+	}
+	// shared by the http and grpc servers
+	if auth, err = loadAuthConfig(); err != nil {
+		log.Fatal().Str("caller", "main.go").Msg(helpers.LogMsg("invalid authentication configuration: ", err.Error()))
+	}
+	go httpServer(ctx, certs)
+	if grpcEnabled() {
+		go grpcServer(ctx, certs)
+	}
+	watchers, err := k8client.NewKubeClients(cacheStore)
 	if err != nil {
 		log.Error().Str("caller", "main.go").Msg(helpers.LogMsg("failed to create kubeclient", err.Error()))
 	}
 	log.Info().Str("caller", "main.go").Msg("starting to watch resources .... starting ....")
 	// Start watching resources
-	if watcher != nil {
+	if len(watchers) > 0 {
 		// Handle system signals for graceful shutdown
 		sigs := make(chan os.Signal, 1)
 		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -45,8 +79,16 @@ func main() {
 			cancel() // Cancel the context to stop goroutines
 		}()
 
-		watcher.StartWatchingResources(ctx, LabelSelector)
-		watcher.Wg.Wait()
+		if k8client.LeaderElectionEnabled() {
+			// only the leader watches, followers serve the leader's snapshot. exit once the leadership is lost
+			if err := k8client.RunLeaderElection(ctx, cacheStore, func(leaderCtx context.Context) {
+				watchResources(leaderCtx, watchers, backend)
+			}); err != nil {
+				log.Error().Str("caller", "main.go").Msg(helpers.LogMsg("failed to run leader election: ", err.Error()))
+			}
+			return
+		}
+		watchResources(ctx, watchers, backend)
 	} else {
 		log.Error().Str("caller", "main.go").Msg("watcher is nil, unable to start watching resources")
 		return
@@ -55,11 +97,152 @@ func main() {
 	// select{} // Ignore notes: here this is not need as we use waitgroup and graceful shutdown
 }
 
-func httpServer(ctx context.Context) {
+// statusFilter builds the status filter from the query parameters and the :namespace path parameter,
+// eg: ?cluster=prod-eu&kind=deployment&labelSelector=team=payments&state=degraded
+func statusFilter(c echo.Context) (helpers.StatusFilter, error) {
+	filter := helpers.StatusFilter{
+		Cluster:       c.QueryParam("cluster"),
+		Kind:          c.QueryParam("kind"),
+		Namespace:     c.QueryParam("namespace"),
+		Name:          c.QueryParam("name"),
+		LabelSelector: c.QueryParam("labelSelector"),
+		State:         c.QueryParam("state"),
+	}
+	if namespace := c.Param("namespace"); namespace != "" {
+		filter.Namespace = namespace
+	}
+	filter.Authorized = namespaceAuthorizer(c)
+	return filter, filter.Validate()
+}
+
+// parseSince accepts a RFC3339 timestamp or a duration relative to now, eg: 2h
+func parseSince(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+type silenceRequest struct {
+	Matchers helpers.StatusFilter `json:"matchers"`
+	Duration string               `json:"duration"`
+	Author   string               `json:"author"`
+	Comment  string               `json:"comment"`
+}
+
+type clusterStatus struct {
+	Ready     bool   `json:"ready"`
+	Status    string `json:"status"`
+	Unhealthy int    `json:"unhealthy"`
+}
+
+type fleet struct {
+	Status    string                   `json:"status"`
+	Unhealthy int                      `json:"unhealthy"`
+	Clusters  map[string]clusterStatus `json:"clusters"`
+}
+
+// fleetStatus aggregates readiness and unhealthy resources of every monitored cluster
+func fleetStatus() fleet {
+	f := fleet{Status: "ok", Clusters: make(map[string]clusterStatus)}
+	for name, ready := range k8client.ClusterReadiness() {
+		cluster := clusterStatus{Ready: ready, Status: "ok"}
+		if !ready {
+			// a cluster we cannot reach cannot be vouched for
+			cluster.Status = "not_ok"
+			f.Status = "not_ok"
+		}
+		f.Clusters[name] = cluster
+	}
+	for _, record := range cacheStore.GetAllStatus(helpers.StatusFilter{}) {
+		if record.Silenced {
+			continue
+		}
+		cluster := f.Clusters[record.Cluster]
+		cluster.Unhealthy++
+		cluster.Status = "not_ok"
+		f.Clusters[record.Cluster] = cluster
+		f.Unhealthy++
+		f.Status = "not_ok"
+	}
+	return f
+}
+
+// health is ok unless a resource matching the query is not healthy, every team can point its
+// load balancer at /healthcheck/v1/namespaces/<namespace>/health or at its own label selector
+func health(c echo.Context) error {
+	if k8client.WarmingUp() {
+		// not a verdict yet, the first full evaluation has not completed
+		return c.String(http.StatusServiceUnavailable, "warming_up")
+	}
+	filter, err := statusFilter(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, helpers.LogMsg("invalid labelSelector: ", err.Error()))
+	}
+	// silenced resources are still reported on /status but do not fail the verdict
+	if helpers.Unsilenced(cacheStore.GetAllStatus(filter)) >= 1 {
+		return c.String(http.StatusServiceUnavailable, "not_ok")
+	}
+	return c.String(http.StatusOK, "ok")
+}
+
+func status(c echo.Context) error {
+	filter, err := statusFilter(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, helpers.LogMsg("invalid labelSelector: ", err.Error()))
+	}
+	return c.JSON(http.StatusOK, cacheStore.GetAllStatus(filter))
+}
+
+func watchResources(ctx context.Context, watchers []*k8client.Watcher, backend helpers.SnapshotBackend) {
+	var persisted sync.WaitGroup
+	if backend != nil {
+		persisted.Add(1)
+		go func() {
+			defer persisted.Done()
+			k8client.PersistSnapshots(ctx, backend, cacheStore)
+		}()
+	}
+	if k8client.KubeEventsEnabled() {
+		// only the watching replica records events, followers would duplicate them
+		go k8client.RecordKubeEvents(ctx, watchers, cacheStore)
+	}
+	for _, watcher := range watchers {
+		watcher.StartWatchingResources(ctx, LabelSelector)
+	}
+	for _, watcher := range watchers {
+		watcher.Wg.Wait()
+	}
+	persisted.Wait() // make sure the final snapshot is written
+}
+
+func httpServer(ctx context.Context, certs *certReloader) {
 	e := echo.New()
+	if auth != nil {
+		e.Use(auth.middleware())
+	}
 
 	e.GET("/readiness", func(c echo.Context) error {
 		ok := k8client.ReadinessProbe()
+		if cluster := c.QueryParam("cluster"); cluster != "" {
+			ok = k8client.ClusterReadiness()[cluster]
+		} else if err := k8client.SnapshotError(); err != nil {
+			// the last snapshot could not be saved, a restart would lose the history
+			if k8client.LeaderElectionEnabled() {
+				c.Response().Header().Set("X-Vitals-Role", k8client.Role())
+			}
+			return c.String(http.StatusServiceUnavailable, helpers.LogMsg("not_ok (snapshot: ", err.Error(), ")"))
+		}
+		if k8client.LeaderElectionEnabled() {
+			c.Response().Header().Set("X-Vitals-Role", k8client.Role())
+			if ok {
+				return c.String(http.StatusOK, helpers.LogMsg("ok (", k8client.Role(), ")"))
+			}
+			return c.String(http.StatusServiceUnavailable, helpers.LogMsg("not_ok (", k8client.Role(), ")"))
+		}
 		if ok {
 			return c.String(http.StatusOK, "ok")
 		} else {
@@ -67,30 +250,141 @@ func httpServer(ctx context.Context) {
 		}
 	})
 
-	e.GET("/healthcheck/v1/health", func(c echo.Context) error {
-		if cacheStore.LenAll() >= 1 {
-			return c.String(http.StatusServiceUnavailable, "not_ok")
+	e.GET("/healthcheck/v1/health", health)
+	e.GET("/healthcheck/v1/namespaces/:namespace/health", health)
+	e.GET("/healthcheck/v1/status", status)
+	e.GET("/healthcheck/v1/namespaces/:namespace/status", status)
+	e.GET("/healthcheck/v1/stream", stream)
+	e.GET("/healthcheck/v1/clusters", func(c echo.Context) error {
+		fleet := fleetStatus()
+		if fleet.Status != "ok" {
+			return c.JSON(http.StatusServiceUnavailable, fleet)
+		}
+		return c.JSON(http.StatusOK, fleet)
+	})
+	e.GET("/healthcheck/v1/groups", func(c echo.Context) error {
+		groups := []helpers.GroupStatus{}
+		for _, group := range k8client.HealthGroups(c.QueryParam("cluster")) {
+			groups = append(groups, cacheStore.GroupHealth(group))
+		}
+		return c.JSON(http.StatusOK, groups)
+	})
+	e.GET("/healthcheck/v1/groups/:name/health", func(c echo.Context) error {
+		for _, group := range k8client.HealthGroups(c.QueryParam("cluster")) {
+			if group.Name != c.Param("name") {
+				continue
+			}
+			if k8client.WarmingUp() {
+				return c.String(http.StatusServiceUnavailable, "warming_up")
+			}
+			status := cacheStore.GroupHealth(group)
+			if anonymous(c) {
+				status = groupVerdict(status)
+			}
+			if status.Status != "ok" {
+				return c.JSON(http.StatusServiceUnavailable, status)
+			}
+			return c.JSON(http.StatusOK, status)
+		}
+		return c.String(http.StatusNotFound, helpers.LogMsg("health group ", c.Param("name"), " not found"))
+	})
+	e.GET("/healthcheck/v1/history", func(c echo.Context) error {
+		filter := helpers.StatusFilter{
+			Cluster:   c.QueryParam("cluster"),
+			Kind:      c.QueryParam("kind"),
+			Namespace: c.QueryParam("namespace"),
+			Name:      c.QueryParam("name"),
+
+			Authorized: namespaceAuthorizer(c),
 		}
-		return c.String(http.StatusOK, "ok")
+		since, err := parseSince(c.QueryParam("since"))
+		if err != nil {
+			return c.String(http.StatusBadRequest, "since must be a RFC3339 timestamp or a duration such as 1h")
+		}
+		return c.JSON(http.StatusOK, cacheStore.History(filter, since))
+	})
+	e.GET("/healthcheck/v1/slo", func(c echo.Context) error {
+		filter := helpers.StatusFilter{
+			Cluster:   c.QueryParam("cluster"),
+			Kind:      c.QueryParam("kind"),
+			Namespace: c.QueryParam("namespace"),
+			Name:      c.QueryParam("name"),
+
+			Authorized: namespaceAuthorizer(c),
+		}
+		return c.JSON(http.StatusOK, cacheStore.Availability(filter, helpers.SLOWindows()))
+	})
+	e.GET("/metrics", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentType, "text/plain; version=0.0.4")
+		c.Response().WriteHeader(http.StatusOK)
+		helpers.WriteMetrics(c.Response(), cacheStore.Availability(helpers.StatusFilter{}, helpers.SLOWindows()))
+		k8client.WriteSnapshotMetrics(c.Response())
+		return nil
 	})
-	e.GET("/healthcheck/v1/status", func(c echo.Context) error {
-		status, err := cacheStore.GetAll()
+	e.GET("/healthcheck/v1/silences", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, cacheStore.Silences())
+	})
+	e.POST("/healthcheck/v1/silences", func(c echo.Context) error {
+		if k8client.Role() == k8client.RoleFollower {
+			// followers replace their silences with the leader snapshot
+			return c.String(http.StatusServiceUnavailable, "silences must be created on the leader")
+		}
+		var req silenceRequest
+		if err := c.Bind(&req); err != nil {
+			return c.String(http.StatusBadRequest, "invalid silence")
+		}
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "duration must be a duration such as 2h")
+		}
+		silence, err := cacheStore.AddSilence(helpers.Silence{Matchers: req.Matchers, Author: req.Author, Comment: req.Comment}, duration)
 		if err != nil {
-			return c.String(http.StatusInternalServerError, "failed to retrieve the status report")
+			return c.String(http.StatusBadRequest, err.Error())
 		}
-		return c.JSON(http.StatusOK, status)
+		log.Info().Str("caller", "main.go").Msg(helpers.LogMsg("silence ", silence.ID, " created by ", silence.Author, " until ", silence.EndsAt.Format(time.RFC3339)))
+		return c.JSON(http.StatusCreated, silence)
+	})
+	e.DELETE("/healthcheck/v1/silences/:id", func(c echo.Context) error {
+		if k8client.Role() == k8client.RoleFollower {
+			return c.String(http.StatusServiceUnavailable, "silences must be deleted on the leader")
+		}
+		if err := cacheStore.DeleteSilence(c.Param("id")); err != nil {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+		return c.NoContent(http.StatusNoContent)
 	})
 	e.GET("/healthcheck/v1/triage", func(c echo.Context) error {
-		return c.String(http.StatusOK, "todo: triage endpoint under development")
+		filter, err := statusFilter(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, helpers.LogMsg("invalid labelSelector: ", err.Error()))
+		}
+		return c.JSON(http.StatusOK, cacheStore.Triage(filter))
+	})
+	e.GET("/healthcheck/v1/inventory", func(c echo.Context) error {
+		filter, err := statusFilter(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, helpers.LogMsg("invalid labelSelector: ", err.Error()))
+		}
+		return c.JSON(http.StatusOK, cacheStore.Inventory(filter))
 	})
 	e.GET("/healthcheck/v1/scrape_configuration", func(c echo.Context) error {
 		kv := cacheStore.GoCacheGetAll()
 		return c.JSON(http.StatusOK, kv)
 	})
+	registerAPIv2(e)
+	dashboard.Register(e)
 	// Start the server in a goroutine
 	go func() {
-		log.Info().Msg("Starting HTTP server on :1323...")
-		if err := e.Start(":1323"); err != nil && err != http.ErrServerClosed {
+		start := func() error { return e.Start(":1323") }
+		if certs != nil {
+			e.TLSServer.Addr = ":1323"
+			e.TLSServer.TLSConfig = certs.config("h2", "http/1.1")
+			start = func() error { return e.StartServer(e.TLSServer) }
+			log.Info().Msg("Starting HTTPS server on :1323...")
+		} else {
+			log.Info().Msg("Starting HTTP server on :1323...")
+		}
+		if err := start(); err != nil && err != http.ErrServerClosed {
 			log.Fatal().Msg("Failed to start server")
 			log.Fatal().Msg(err.Error())
 		}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// openAPI is the subset of an OpenAPI 3 document generated from apiRoutes, the schemas are reflected from the go types
+// of the responses so that a field added to a response is documented without touching the spec
+type openAPI struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"` // operations keyed by path and lower case method
+	Components openAPIComponents                      `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*schema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIBody               `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"` // keyed by status code or default
+}
+
+type openAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type openAPIBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+}
+
+const schemaRefPrefix = "#/components/schemas/"
+
+var echoParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// openAPIPath converts an echo path to an openapi one, eg: /groups/:name/health to /groups/{name}/health
+func openAPIPath(path string) string {
+	return echoParam.ReplaceAllString(path, "{$1}")
+}
+
+// openAPISpec documents the routes
+func openAPISpec(routes []apiRoute) openAPI {
+	spec := openAPI{
+		OpenAPI:    "3.0.3",
+		Info:       openAPIInfo{Title: "k8sClusterVitals", Version: "v2", Description: "Health of the workloads, secrets, configmaps and custom resources of kubernetes clusters"},
+		Paths:      make(map[string]map[string]openAPIOperation),
+		Components: openAPIComponents{Schemas: make(map[string]*schema)},
+	}
+	reflector := schemaReflector{schemas: spec.Components.Schemas, types: make(map[string]reflect.Type)}
+	errorSchema := reflector.schemaOf(reflect.TypeOf(apiError{}))
+	for _, route := range routes {
+		operation := openAPIOperation{OperationID: route.OperationID, Summary: route.Summary, Responses: make(map[string]openAPIResponse)}
+		for _, param := range route.Params {
+			operation.Parameters = append(operation.Parameters, openAPIParameter{Name: param.Name, In: param.In, Description: param.Description, Required: param.In == "path", Schema: &schema{Type: "string"}})
+		}
+		if route.Body != nil {
+			operation.RequestBody = &openAPIBody{Required: true, Content: map[string]openAPIMediaType{echo.MIMEApplicationJSON: {Schema: reflector.schemaOf(reflect.TypeOf(route.Body))}}}
+		}
+		success := openAPIResponse{Description: http.StatusText(route.Status)}
+		if route.Response != nil {
+			success.Content = reflector.content(route.Response, route.EventStream)
+		}
+		operation.Responses[strconv.Itoa(route.Status)] = success
+		if route.Unhealthy {
+			operation.Responses[strconv.Itoa(http.StatusServiceUnavailable)] = openAPIResponse{Description: "Not healthy or warming up", Content: success.Content}
+		}
+		operation.Responses["default"] = openAPIResponse{Description: "Error", Content: map[string]openAPIMediaType{
+			echo.MIMEApplicationJSON: {Schema: errorSchema},
+			echo.MIMETextPlain:       {Schema: &schema{Type: "string"}},
+		}}
+		path := openAPIPath(route.Path)
+		if spec.Paths[path] == nil {
+			spec.Paths[path] = make(map[string]openAPIOperation)
+		}
+		spec.Paths[path][strings.ToLower(route.Method)] = operation
+	}
+	return spec
+}
+
+// schemaReflector builds schemas from go types following encoding/json, named structs become components
+type schemaReflector struct {
+	schemas map[string]*schema
+	types   map[string]reflect.Type // go type of each component, to tell apart types with the same name
+}
+
+func (r schemaReflector) content(body interface{}, eventStream bool) map[string]openAPIMediaType {
+	if eventStream {
+		// every event carries a json encoded message as its data
+		return map[string]openAPIMediaType{"text/event-stream": {Schema: r.schemaOf(reflect.TypeOf(body))}}
+	}
+	content := map[string]openAPIMediaType{echo.MIMEApplicationJSON: {Schema: r.schemaOf(reflect.TypeOf(body))}}
+	if _, ok := body.(texter); ok {
+		content[echo.MIMETextPlain] = openAPIMediaType{Schema: &schema{Type: "string"}}
+	}
+	return content
+}
+
+func (r schemaReflector) schemaOf(t reflect.Type) *schema {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &schema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(time.Duration(0)):
+		return &schema{Type: "integer", Format: "int64"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := r.schemaOf(t.Elem())
+		if s.Ref != "" {
+			return s // a nullable $ref would drop the nullable in openapi 3.0
+		}
+		s.Nullable = true
+		return s
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &schema{Type: "string", Format: "byte"}
+		}
+		return &schema{Type: "array", Items: r.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: r.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		name := r.componentName(t)
+		if _, ok := r.schemas[name]; !ok {
+			r.schemas[name] = &schema{} // placeholder for recursive types
+			*r.schemas[name] = *r.structSchema(t)
+		}
+		return &schema{Ref: schemaRefPrefix + name}
+	}
+	return &schema{} // interface{}, any value
+}
+
+// componentName is the exported go name of the type, prefixed with its package on a clash
+func (r schemaReflector) componentName(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if known, ok := r.types[name]; ok && known != t {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	r.types[name] = t
+	return name
+}
+
+func (r schemaReflector) structSchema(t reflect.Type) *schema {
+	s := &schema{Type: "object", Properties: make(map[string]*schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			// embedded structs are flattened by encoding/json
+			embedded := r.structSchema(field.Type)
+			for property, propertySchema := range embedded.Properties {
+				s.Properties[property] = propertySchema
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.Properties[name] = r.schemaOf(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// checkContract makes sure the served v2 routes and the document agree: every route registered under the v2 prefix
+// is documented, every documented operation is served and every schema reference resolves
+func checkContract(e *echo.Echo, routes []apiRoute, spec openAPI) error {
+	var problems []string
+	served := make(map[string]bool)
+	for _, route := range e.Routes() {
+		if !strings.HasPrefix(route.Path, apiV2) {
+			continue
+		}
+		path, method := openAPIPath(route.Path), strings.ToLower(route.Method)
+		served[method+" "+path] = true
+		if _, ok := spec.Paths[path][method]; !ok {
+			problems = append(problems, helpers.LogMsg(route.Method, " ", route.Path, " is served but not documented"))
+		}
+	}
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			if !served[method+" "+path] {
+				problems = append(problems, helpers.LogMsg(strings.ToUpper(method), " ", path, " is documented but not served"))
+			}
+			for _, param := range operation.Parameters {
+				if param.In == "path" && !strings.Contains(path, "{"+param.Name+"}") {
+					problems = append(problems, helpers.LogMsg("path parameter ", param.Name, " is not part of ", path))
+				}
+			}
+		}
+	}
+	for _, route := range routes {
+		if route.Status == 0 || route.Handler == nil || route.OperationID == "" {
+			problems = append(problems, helpers.LogMsg(route.Method, " ", route.Path, " needs an operation id, a status and a handler"))
+		}
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	for _, ref := range regexp.MustCompile(`"\$ref":"([^"]+)"`).FindAllStringSubmatch(string(data), -1) {
+		if _, ok := spec.Components.Schemas[strings.TrimPrefix(ref[1], schemaRefPrefix)]; !ok {
+			problems = append(problems, helpers.LogMsg("unresolved schema ", ref[1]))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// contractCheckEnabled validates every json response of the v2 api against the document, for staging and ci
+func contractCheckEnabled() bool {
+	return os.Getenv("API_CONTRACT_CHECK") == "true"
+}
+
+// contractValidator logs the v2 responses which do not match their operation, event streams are left alone as
+// the dump would buffer them for as long as they are open
+func contractValidator(routes []apiRoute, spec openAPI) echo.MiddlewareFunc {
+	eventStreams := make(map[string]bool)
+	for _, route := range routes {
+		if route.EventStream {
+			eventStreams[route.Path] = true
+		}
+	}
+	return middleware.BodyDumpWithConfig(middleware.BodyDumpConfig{
+		Skipper: func(c echo.Context) bool {
+			return !strings.HasPrefix(c.Path(), apiV2) || eventStreams[c.Path()]
+		},
+		Handler: func(c echo.Context, _ []byte, body []byte) {
+			err := validateResponse(spec, c.Request().Method, c.Path(), c.Response().Status, c.Response().Header().Get(echo.HeaderContentType), body)
+			if err != nil {
+				log.Error().Str("caller", "openapi.go").Msg(helpers.LogMsg("contract violation on ", c.Request().Method, " ", c.Path(), ": ", err.Error()))
+			}
+		},
+	})
+}
+
+// validateResponse checks a response of the echo route against the document: the status, or the default error
+// response, has to document the media type and json bodies have to match its schema
+func validateResponse(spec openAPI, method string, path string, status int, contentType string, body []byte) error {
+	operation, ok := spec.Paths[openAPIPath(path)][strings.ToLower(method)]
+	if !ok {
+		return fmt.Errorf("%s %s is not documented", method, path)
+	}
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		response = operation.Responses["default"]
+	}
+	if len(body) == 0 && response.Content == nil {
+		return nil // eg: 204
+	}
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	content, ok := response.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("%s is not documented for status %d", mediaType, status)
+	}
+	if mediaType != echo.MIMEApplicationJSON {
+		return nil // text/plain is a string
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return err
+	}
+	return validateSchema(spec, content.Schema, value, "$")
+}
+
+// validateSchema checks a decoded json value against the schema
+func validateSchema(spec openAPI, s *schema, value interface{}, at string) error {
+	if s == nil {
+		return fmt.Errorf("%s: no schema documented", at)
+	}
+	if s.Ref != "" {
+		return validateSchema(spec, spec.Components.Schemas[strings.TrimPrefix(s.Ref, schemaRefPrefix)], value, at)
+	}
+	if value == nil {
+		if s.Nullable || s.Type == "" || s.Type == "array" || s.Type == "object" {
+			return nil // nil slices and maps are encoded as null
+		}
+		return fmt.Errorf("%s: null is not a %s", at, s.Type)
+	}
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object", at)
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s: missing required property %s", at, name)
+			}
+		}
+		for name, property := range object {
+			propertySchema, ok := s.Properties[name]
+			if !ok {
+				propertySchema = s.AdditionalProperties
+			}
+			if propertySchema == nil {
+				return fmt.Errorf("%s: undocumented property %s", at, name)
+			}
+			if err := validateSchema(spec, propertySchema, property, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an array", at)
+		}
+		for i, item := range array {
+			if err := validateSchema(spec, s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected a string", at)
+		}
+	case "integer", "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected a %s", at, s.Type)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean", at)
+		}
+	}
+	return nil
+}
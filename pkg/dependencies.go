@@ -0,0 +1,182 @@
+package helpers
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ParseResourceRef parses a kind/name or kind/namespace/name reference, eg: statefulset/default/postgres
+func ParseResourceRef(ref string) (StatusFilter, error) {
+	parts := strings.Split(ref, "/")
+	for _, part := range parts {
+		if part == "" {
+			parts = nil
+			break
+		}
+	}
+	switch len(parts) {
+	case 2:
+		return StatusFilter{Kind: parts[0], Name: parts[1]}, nil
+	case 3:
+		return StatusFilter{Kind: parts[0], Namespace: parts[1], Name: parts[2]}, nil
+	}
+	return StatusFilter{}, errors.New(LogMsg("invalid resource reference ", ref, ", expected kind/name or kind/namespace/name"))
+}
+
+// ResourceRef is the kind/namespace/name reference of the record
+func ResourceRef(record StatusRecord) string {
+	return LogMsg(strings.ToLower(record.Kind), "/", record.Namespace, "/", record.Name)
+}
+
+// dependencyFilter resolves a dependency of the record within its cluster
+func dependencyFilter(record StatusRecord, ref string) (StatusFilter, bool) {
+	filter, err := ParseResourceRef(ref)
+	if err != nil {
+		return filter, false
+	}
+	filter.Cluster = record.Cluster
+	return filter, true
+}
+
+// impactedBy returns the keys of the unsilenced records the record depends on
+func impactedBy(records map[string]StatusRecord, record StatusRecord) []string {
+	var keys []string
+	for _, ref := range record.DependsOn {
+		filter, ok := dependencyFilter(record, ref)
+		if !ok {
+			continue
+		}
+		for key, dependency := range records {
+			if !dependency.Silenced && filter.Matches(dependency) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// applyDependencies sets impactedBy on every record with an unhealthy dependency
+func applyDependencies(records map[string]StatusRecord) {
+	for key, record := range records {
+		record.ImpactedBy = nil
+		for _, dependency := range impactedBy(records, record) {
+			if dependency != key {
+				record.ImpactedBy = append(record.ImpactedBy, ResourceRef(records[dependency]))
+			}
+		}
+		records[key] = record
+	}
+}
+
+// TriageFinding is an unhealthy resource none of whose dependencies is unhealthy, along with the resources it impacts
+type TriageFinding struct {
+	Key       string       `json:"key"`
+	RootCause StatusRecord `json:"rootCause"`
+	Impacted  []string     `json:"impacted,omitempty"` // keys of the unhealthy resources depending on the root cause
+}
+
+// Triage points the unsilenced unhealthy resources matching the filter at their root causes, the ones impacting the most
+// resources first. root causes are reported even when the filter does not match them
+func (kvs *KeyValueStore) Triage(filter StatusFilter) []TriageFinding {
+	records := kvs.GetAllStatus(StatusFilter{})
+	findings := make(map[string]*TriageFinding)
+	graph := newDependencyGraph(records)
+	for key, record := range records {
+		if record.Silenced || !filter.Matches(record) {
+			continue
+		}
+		for _, root := range graph.rootCauses(key) {
+			finding, ok := findings[root]
+			if !ok {
+				finding = &TriageFinding{Key: root, RootCause: records[root]}
+				if cause := finding.RootCause; filter.Authorized != nil && !filter.Authorized(cause.Namespace) {
+					// the caller may not see the namespace of the root cause, only where and what it is
+					finding.RootCause = StatusRecord{Cluster: cause.Cluster, Kind: cause.Kind, Namespace: cause.Namespace, Name: cause.Name, State: cause.State, Since: cause.Since, CheckedAt: cause.CheckedAt}
+				}
+				findings[root] = finding
+			}
+			if root != key {
+				finding.Impacted = append(finding.Impacted, key)
+			}
+		}
+	}
+	triage := make([]TriageFinding, 0, len(findings))
+	for _, finding := range findings {
+		sort.Strings(finding.Impacted)
+		triage = append(triage, *finding)
+	}
+	sort.Slice(triage, func(i, j int) bool {
+		if len(triage[i].Impacted) != len(triage[j].Impacted) {
+			return len(triage[i].Impacted) > len(triage[j].Impacted)
+		}
+		return triage[i].Key < triage[j].Key
+	})
+	return triage
+}
+
+// dependencyGraph holds the unhealthy dependencies of the records, keys without any are root causes
+type dependencyGraph struct {
+	edges map[string][]string        // keys of the unsilenced unhealthy dependencies keyed by record key
+	reach map[string]map[string]bool // direct and transitive dependencies, filled on demand
+}
+
+func newDependencyGraph(records map[string]StatusRecord) *dependencyGraph {
+	g := &dependencyGraph{edges: make(map[string][]string, len(records)), reach: make(map[string]map[string]bool)}
+	for key, record := range records {
+		for _, dependency := range impactedBy(records, record) {
+			if dependency != key {
+				g.edges[key] = append(g.edges[key], dependency)
+			}
+		}
+	}
+	return g
+}
+
+// reachable returns the direct and transitive unhealthy dependencies of the key
+func (g *dependencyGraph) reachable(key string) map[string]bool {
+	if reach, ok := g.reach[key]; ok {
+		return reach
+	}
+	reach := make(map[string]bool)
+	queue := append([]string(nil), g.edges[key]...)
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if reach[next] {
+			continue
+		}
+		reach[next] = true
+		queue = append(queue, g.edges[next]...)
+	}
+	g.reach[key] = reach
+	return reach
+}
+
+// isRoot reports whether every unhealthy dependency of the key depends back on it, either the key has none or it sits
+// in a dependency cycle without unhealthy dependencies outside of the cycle
+func (g *dependencyGraph) isRoot(key string) bool {
+	for dependency := range g.reachable(key) {
+		if dependency != key && !g.reachable(dependency)[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// rootCauses returns the root causes among the unhealthy dependencies of the key, a resource in a dependency cycle
+// is its own root cause unless the cycle depends on another unhealthy resource, then that one is blamed
+func (g *dependencyGraph) rootCauses(key string) []string {
+	if g.isRoot(key) {
+		return []string{key}
+	}
+	var roots []string
+	for dependency := range g.reachable(key) {
+		if g.isRoot(dependency) {
+			roots = append(roots, dependency)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
@@ -0,0 +1,107 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// dependencyStore reports the deployments of the shop namespace unavailable with their dependencies,
+// eg: {"a": {"b"}} makes deployment/shop/a depend on deployment/shop/b
+func dependencyStore(t *testing.T, dependencies map[string][]string, silenced ...string) *KeyValueStore {
+	t.Helper()
+	kvs := NewKeyValueStore()
+	until := time.Now().Add(time.Hour)
+	for name, needs := range dependencies {
+		record := StatusRecord{Kind: "deployment", Namespace: "shop", Name: name, State: StateUnavailable, CheckedAt: time.Now()}
+		for _, need := range needs {
+			record.DependsOn = append(record.DependsOn, "deployment/shop/"+need)
+		}
+		for _, s := range silenced {
+			if s == name {
+				record.SilencedUntil = &until
+			}
+		}
+		if err := kvs.SetStatus(key(name), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return kvs
+}
+
+func key(name string) string {
+	return "deployment.apps/shop/" + name
+}
+
+func keys(names ...string) []string {
+	var keys []string
+	for _, name := range names {
+		keys = append(keys, key(name))
+	}
+	return keys
+}
+
+func TestTriage(t *testing.T) {
+	tests := []struct {
+		name         string
+		dependencies map[string][]string
+		silenced     []string
+		want         []TriageFinding
+	}{
+		{"independent", map[string][]string{"a": nil, "b": nil},
+			nil, []TriageFinding{{Key: key("a")}, {Key: key("b")}}},
+		{"chain", map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil},
+			nil, []TriageFinding{{Key: key("c"), Impacted: keys("a", "b")}}},
+		{"diamond", map[string][]string{"a": {"b", "c"}, "b": {"d"}, "c": {"d"}, "d": nil},
+			nil, []TriageFinding{{Key: key("d"), Impacted: keys("a", "b", "c")}}},
+		// nothing outside of the cycle explains it, every resource of the cycle is its own root cause
+		{"cycle", map[string][]string{"a": {"b"}, "b": {"a"}},
+			nil, []TriageFinding{{Key: key("a")}, {Key: key("b")}}},
+		{"cycle behind a dependency", map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}},
+			nil, []TriageFinding{{Key: key("b"), Impacted: keys("a")}, {Key: key("c"), Impacted: keys("a")}}},
+		{"cycle on a root cause", map[string][]string{"a": {"b"}, "b": {"c", "d"}, "c": {"b"}, "d": nil},
+			nil, []TriageFinding{{Key: key("d"), Impacted: keys("a", "b", "c")}}},
+		// a silenced resource is neither reported nor blamed, its dependents become the root causes
+		{"silenced root", map[string][]string{"a": {"b", "c"}, "b": {"d"}, "c": {"d"}, "d": nil},
+			[]string{"d"}, []TriageFinding{{Key: key("b"), Impacted: keys("a")}, {Key: key("c"), Impacted: keys("a")}}},
+		{"silenced dependent", map[string][]string{"a": {"b"}, "b": nil},
+			[]string{"a"}, []TriageFinding{{Key: key("b")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kvs := dependencyStore(t, tt.dependencies, tt.silenced...)
+			triage := kvs.Triage(StatusFilter{})
+			got := make([]TriageFinding, 0, len(triage))
+			for _, finding := range triage {
+				if finding.RootCause.Name == "" {
+					t.Fatalf("finding %s without its root cause", finding.Key)
+				}
+				got = append(got, TriageFinding{Key: finding.Key, Impacted: finding.Impacted})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("triage = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// c and e depend on each other, the cycle depends on d which is blamed from every entry point
+func TestRootCauses(t *testing.T) {
+	kvs := dependencyStore(t, map[string][]string{"a": {"b", "c"}, "b": {"d"}, "c": {"d", "e"}, "d": nil, "e": {"c"}})
+	graph := newDependencyGraph(kvs.GetAllStatus(StatusFilter{}))
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"a", keys("d")},
+		{"b", keys("d")},
+		{"c", keys("d")},
+		{"d", keys("d")},
+		{"e", keys("d")},
+	}
+	for _, tt := range tests {
+		if got := graph.rootCauses(key(tt.name)); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("root causes of %s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
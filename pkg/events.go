@@ -0,0 +1,77 @@
+package helpers
+
+import "time"
+
+// number of events kept for subscribers resuming with a last event id
+var eventLogSize = envInt("EVENT_LOG_SIZE", 1000)
+
+// event ids start at the seconds since eventEpoch shifted by epochBits, ids of an earlier process are lower and those
+// of a later one are higher than any id handed out here. 20 bits leave 1M events per second of uptime and keep the ids
+// below 2^53 for javascript clients for the next 270 years
+const epochBits = 20
+
+var eventEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func newEpoch() uint64 {
+	return uint64(time.Since(eventEpoch)/time.Second) << epochBits
+}
+
+// Event is a state change pushed to the stream subscribers, ids grow monotonically
+type Event struct {
+	ID         uint64     `json:"id"`
+	Transition Transition `json:"transition"`
+}
+
+// events a subscriber may lag behind before it is dropped, it resumes from its last event id when it reconnects
+const subscriberBuffer = 256
+
+// Subscribe returns a channel receiving every state change after the call and the id of the last event published
+// before it. with a non zero lastEventID the events published after it are replayed first, resumed is false when
+// they are no longer in the log or the id was handed out by another process, eg: before a restart or by another
+// replica, and the caller has to start over from a snapshot. the channel is closed by cancel or when the subscriber
+// falls behind
+func (kvs *KeyValueStore) Subscribe(lastEventID uint64) (events <-chan Event, last uint64, resumed bool, cancel func()) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	ch := make(chan Event, subscriberBuffer+len(kvs.events))
+	last = kvs.lastEventID
+	if lastEventID >= kvs.epoch && (lastEventID == last || (lastEventID < last && kvs.events[0].ID <= lastEventID+1)) {
+		resumed = true
+		for _, event := range kvs.events {
+			if event.ID > lastEventID {
+				ch <- event
+			}
+		}
+	}
+	id := kvs.nextSubscriber
+	kvs.nextSubscriber++
+	kvs.subscribers[id] = ch
+	cancel = func() {
+		kvs.mu.Lock()
+		defer kvs.mu.Unlock()
+		if ch, ok := kvs.subscribers[id]; ok {
+			delete(kvs.subscribers, id)
+			close(ch)
+		}
+	}
+	return ch, last, resumed, cancel
+}
+
+// publish appends the transition to the event log and hands it to the subscribers, callers hold kvs.mu
+func (kvs *KeyValueStore) publish(t Transition) {
+	kvs.lastEventID++
+	event := Event{ID: kvs.lastEventID, Transition: t}
+	kvs.events = append(kvs.events, event)
+	if len(kvs.events) > eventLogSize {
+		kvs.events = kvs.events[len(kvs.events)-eventLogSize:]
+	}
+	for id, ch := range kvs.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// never block the evaluation on a slow subscriber
+			delete(kvs.subscribers, id)
+			close(ch)
+		}
+	}
+}
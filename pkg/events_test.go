@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribeResume(t *testing.T) {
+	kvs := NewKeyValueStore()
+	record := StatusRecord{Kind: "deployment", Namespace: "payments", Name: "api", State: StateUnavailable, CheckedAt: time.Now()}
+	if err := kvs.SetStatus("deployment.apps/payments/api", record); err != nil {
+		t.Fatal(err)
+	}
+	first := kvs.lastEventID
+	if err := kvs.Delete("deployment.apps/payments/api"); err != nil {
+		t.Fatal(err)
+	}
+	// an earlier process handed out the same sequence below its own epoch
+	previousProcess := kvs.epoch - 1<<epochBits + 1
+	tests := []struct {
+		name        string
+		lastEventID uint64
+		resumed     bool
+		replayed    int
+	}{
+		{"fresh", 0, false, 0},
+		{"missed one", first, true, 1},
+		{"up to date", kvs.lastEventID, true, 0},
+		{"earlier process", previousProcess, false, 0},
+		{"later process", kvs.lastEventID + 1<<epochBits, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, _, resumed, cancel := kvs.Subscribe(tt.lastEventID)
+			defer cancel()
+			if resumed != tt.resumed || len(events) != tt.replayed {
+				t.Fatalf("resumed %v with %d events, want %v with %d", resumed, len(events), tt.resumed, tt.replayed)
+			}
+		})
+	}
+}
+
+// a restored key the store did not hold changes from healthy
+func TestReplaceStatusPublishesFromHealthy(t *testing.T) {
+	kvs := NewKeyValueStore()
+	events, _, _, cancel := kvs.Subscribe(0)
+	defer cancel()
+	record := StatusRecord{Kind: "deployment", Namespace: "payments", Name: "api", State: StateDegraded, CheckedAt: time.Now()}
+	if err := kvs.ReplaceStatus(map[string]StatusRecord{"deployment.apps/payments/api": record}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if event.Transition.From != StateHealthy || event.Transition.To != StateDegraded {
+			t.Fatalf("transition %s -> %s, want healthy -> degraded", event.Transition.From, event.Transition.To)
+		}
+	default:
+		t.Fatal("no transition published")
+	}
+}
+
+// javascript numbers are exact up to 2^53, the dashboard and browsers parse the ids as numbers
+func TestEventIDsStayJavascriptSafe(t *testing.T) {
+	far := uint64(time.Date(2290, time.January, 1, 0, 0, 0, 0, time.UTC).Sub(eventEpoch)/time.Second) << epochBits
+	if far+1<<epochBits >= 1<<53 {
+		t.Fatalf("event ids reach %d in 2290, above 2^53", far)
+	}
+	if newEpoch() == 0 || newEpoch() >= far {
+		t.Fatalf("epoch %d out of range", newEpoch())
+	}
+}
@@ -0,0 +1,183 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// policies deciding whether a health group is healthy
+const (
+	GroupPolicyAll      = "all"      // every member is healthy
+	GroupPolicyQuorum   = "quorum"   // at least quorum members are healthy
+	GroupPolicyWeighted = "weighted" // the healthy members carry at least threshold of the total weight
+)
+
+// HealthGroup is a named set of resources with its own aggregate verdict, defined in the health-groups key of the
+// scrape configuration either as a list of members or with a policy:
+//
+//	checkout: [deployment/cart, deployment/payments, statefulset/redis, secret/shop/stripe-key]
+//	search:
+//	  policy: quorum
+//	  quorum: 2
+//	  members: [deployment/search-a, deployment/search-b, deployment/search-c]
+type HealthGroup struct {
+	Name      string        `yaml:"-" json:"name"`
+	Cluster   string        `yaml:"-" json:"cluster,omitempty"`
+	Policy    string        `yaml:"policy" json:"policy"`
+	Quorum    int           `yaml:"quorum" json:"quorum,omitempty"`       // defaults to a majority of the members
+	Threshold float64       `yaml:"threshold" json:"threshold,omitempty"` // share of the total weight, defaults to 0.5
+	Members   []GroupMember `yaml:"members" json:"members"`
+}
+
+func (g *HealthGroup) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var members []GroupMember
+	if err := unmarshal(&members); err == nil {
+		*g = HealthGroup{Policy: GroupPolicyAll, Members: members}
+		return nil
+	}
+	type plain HealthGroup
+	group := plain{Policy: GroupPolicyAll}
+	if err := unmarshal(&group); err != nil {
+		return err
+	}
+	*g = HealthGroup(group)
+	return nil
+}
+
+// Validate reports an unknown policy, a quorum or threshold the group cannot reach or a malformed member reference
+func (g HealthGroup) Validate() error {
+	switch g.Policy {
+	case GroupPolicyAll, GroupPolicyQuorum, GroupPolicyWeighted:
+	default:
+		return errors.New(LogMsg("unknown policy ", g.Policy, ", expected all, quorum or weighted"))
+	}
+	if len(g.Members) == 0 {
+		return errors.New("a group needs at least one member")
+	}
+	// a group which can never be ok is a configuration mistake, not an outage
+	if g.Policy == GroupPolicyQuorum && g.Quorum > len(g.Members) {
+		return fmt.Errorf("quorum %d is larger than the %d members", g.Quorum, len(g.Members))
+	}
+	if g.Policy == GroupPolicyWeighted && g.Threshold > 1 {
+		return fmt.Errorf("threshold %g is above 1, the share of the total weight", g.Threshold)
+	}
+	for _, member := range g.Members {
+		if _, err := member.Filter(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GroupMember references a resource as kind/name or kind/namespace/name, eg: deployment/cart
+type GroupMember struct {
+	Resource string  `yaml:"resource" json:"resource"`
+	Weight   float64 `yaml:"weight" json:"weight,omitempty"` // defaults to 1, only used by the weighted policy
+}
+
+func (m *GroupMember) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var resource string
+	if err := unmarshal(&resource); err == nil {
+		*m = GroupMember{Resource: resource}
+		return nil
+	}
+	type plain GroupMember
+	var member plain
+	if err := unmarshal(&member); err != nil {
+		return err
+	}
+	*m = GroupMember(member)
+	return nil
+}
+
+// Filter translates the member reference into a status filter, a member without namespace matches every namespace
+func (m GroupMember) Filter() (StatusFilter, error) {
+	return ParseResourceRef(m.Resource)
+}
+
+func (m GroupMember) weight() float64 {
+	if m.Weight <= 0 {
+		return 1
+	}
+	return m.Weight
+}
+
+// GroupStatus is the verdict of a health group
+type GroupStatus struct {
+	Name    string              `json:"name"`
+	Cluster string              `json:"cluster,omitempty"`
+	Policy  string              `json:"policy"`
+	Status  string              `json:"status"` // ok or not_ok, warming_up before the first evaluation
+	Reason  string              `json:"reason,omitempty"`
+	Members []GroupMemberStatus `json:"members"`
+}
+
+type GroupMemberStatus struct {
+	Resource string  `json:"resource"`
+	State    string  `json:"state"` // unknown until the resource has been evaluated
+	Reason   string  `json:"reason,omitempty"`
+	Weight   float64 `json:"weight"`
+}
+
+// state of a member which has not been evaluated, eg: a typo in the group or a resource without the scrape label
+const stateUnknown = "unknown"
+
+// GroupHealth evaluates the members of the group against the store, silenced resources count as healthy
+func (kvs *KeyValueStore) GroupHealth(group HealthGroup) GroupStatus {
+	status := GroupStatus{Name: group.Name, Cluster: group.Cluster, Policy: group.Policy, Status: "not_ok"}
+	if err := group.Validate(); err != nil {
+		status.Reason = err.Error()
+		return status
+	}
+	healthy, healthyWeight, totalWeight := 0, 0.0, 0.0
+	for _, member := range group.Members {
+		filter, _ := member.Filter()
+		filter.Cluster = group.Cluster
+		memberStatus := GroupMemberStatus{Resource: member.Resource, State: StateHealthy, Weight: member.weight()}
+		if len(kvs.Inventory(filter)) == 0 {
+			memberStatus.State = stateUnknown
+			memberStatus.Reason = "not monitored"
+		}
+		// a member may match several resources, eg: statefulset/redis in every namespace, report the first key
+		records := kvs.GetAllStatus(filter)
+		keys := make([]string, 0, len(records))
+		for key := range records {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if record := records[key]; !record.Silenced {
+				memberStatus.State, memberStatus.Reason = record.State, record.Reason
+				break
+			}
+		}
+		totalWeight += memberStatus.Weight
+		if memberStatus.State == StateHealthy {
+			healthy++
+			healthyWeight += memberStatus.Weight
+		}
+		status.Members = append(status.Members, memberStatus)
+	}
+	ok := false
+	switch group.Policy {
+	case GroupPolicyAll:
+		ok = healthy == len(group.Members)
+	case GroupPolicyQuorum:
+		quorum := group.Quorum
+		if quorum <= 0 {
+			quorum = len(group.Members)/2 + 1
+		}
+		ok = healthy >= quorum
+	case GroupPolicyWeighted:
+		threshold := group.Threshold
+		if threshold <= 0 {
+			threshold = 0.5
+		}
+		ok = healthyWeight/totalWeight >= threshold
+	}
+	if ok {
+		status.Status = "ok"
+	}
+	return status
+}
@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// groupStore monitors search-a and search-b healthy, search-c unavailable and search-d unavailable but silenced
+func groupStore(t *testing.T) *KeyValueStore {
+	t.Helper()
+	kvs := NewKeyValueStore()
+	until := time.Now().Add(time.Hour)
+	for _, record := range []StatusRecord{
+		{Name: "search-a", State: StateHealthy},
+		{Name: "search-b", State: StateHealthy},
+		{Name: "search-c", State: StateUnavailable, Reason: "0/3 available"},
+		{Name: "search-d", State: StateUnavailable, SilencedUntil: &until},
+	} {
+		record.Kind, record.Namespace, record.CheckedAt = "deployment", "search", time.Now()
+		key := LogMsg("deployment.apps/search/", record.Name)
+		kvs.Observe(key, record)
+		if record.State != StateHealthy {
+			if err := kvs.SetStatus(key, record); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return kvs
+}
+
+// members parses kind/name references with an optional =weight suffix
+func members(refs ...string) []GroupMember {
+	var members []GroupMember
+	for _, ref := range refs {
+		member := GroupMember{Resource: ref}
+		if resource, weight, ok := strings.Cut(ref, "="); ok {
+			member.Resource = resource
+			member.Weight, _ = strconv.ParseFloat(weight, 64)
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+func TestGroupHealth(t *testing.T) {
+	kvs := groupStore(t)
+	tests := []struct {
+		name   string
+		group  HealthGroup
+		status string
+	}{
+		{"all healthy", HealthGroup{Policy: GroupPolicyAll, Members: members("deployment/search-a", "deployment/search/search-b")}, "ok"},
+		{"silenced counts as healthy", HealthGroup{Policy: GroupPolicyAll, Members: members("deployment/search-a", "deployment/search-d")}, "ok"},
+		{"all with one down", HealthGroup{Policy: GroupPolicyAll, Members: members("deployment/search-a", "deployment/search-c")}, "not_ok"},
+		{"not monitored", HealthGroup{Policy: GroupPolicyAll, Members: members("deployment/search-a", "deployment/search-x")}, "not_ok"},
+		{"default majority", HealthGroup{Policy: GroupPolicyQuorum, Members: members("deployment/search-a", "deployment/search-b", "deployment/search-c")}, "ok"},
+		{"majority lost", HealthGroup{Policy: GroupPolicyQuorum, Members: members("deployment/search-a", "deployment/search-c", "deployment/search-x")}, "not_ok"},
+		{"explicit quorum", HealthGroup{Policy: GroupPolicyQuorum, Quorum: 3, Members: members("deployment/search-a", "deployment/search-b", "deployment/search-c")}, "not_ok"},
+		{"quorum above the members", HealthGroup{Policy: GroupPolicyQuorum, Quorum: 4, Members: members("deployment/search-a", "deployment/search-b", "deployment/search-d")}, "not_ok"},
+		{"weighted default half", HealthGroup{Policy: GroupPolicyWeighted, Members: members("deployment/search-a=1", "deployment/search-c=1")}, "ok"},
+		{"weighted heavy member down", HealthGroup{Policy: GroupPolicyWeighted, Members: members("deployment/search-a=1", "deployment/search-c=3")}, "not_ok"},
+		{"weighted threshold", HealthGroup{Policy: GroupPolicyWeighted, Threshold: 0.75, Members: members("deployment/search-a=3", "deployment/search-c=1")}, "ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.group.Name = "search"
+			status := kvs.GroupHealth(tt.group)
+			if status.Status != tt.status {
+				t.Fatalf("status %s (%s), want %s", status.Status, status.Reason, tt.status)
+			}
+		})
+	}
+}
+
+// a member matching several resources reports the same one every time
+func TestGroupMemberReasonIsDeterministic(t *testing.T) {
+	kvs := NewKeyValueStore()
+	for _, namespace := range []string{"cache-b", "cache-a", "cache-c"} {
+		key := LogMsg("statefulset.apps/", namespace, "/redis")
+		record := StatusRecord{Kind: "statefulset", Namespace: namespace, Name: "redis", State: StateDegraded, Reason: LogMsg(namespace, " 1/3 ready"), CheckedAt: time.Now()}
+		kvs.Observe(key, record)
+		if err := kvs.SetStatus(key, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	group := HealthGroup{Name: "cache", Policy: GroupPolicyAll, Members: members("statefulset/redis")}
+	for i := 0; i < 20; i++ {
+		if reason := kvs.GroupHealth(group).Members[0].Reason; reason != "cache-a 1/3 ready" {
+			t.Fatalf("reason %q, want the first resource by key", reason)
+		}
+	}
+}
+
+func TestGroupValidate(t *testing.T) {
+	three := members("deployment/search-a", "deployment/search-b", "deployment/search-c")
+	tests := []struct {
+		name  string
+		group HealthGroup
+		err   string
+	}{
+		{"all", HealthGroup{Policy: GroupPolicyAll, Members: three}, ""},
+		{"unknown policy", HealthGroup{Policy: "any", Members: three}, "unknown policy"},
+		{"no members", HealthGroup{Policy: GroupPolicyAll}, "at least one member"},
+		{"bad reference", HealthGroup{Policy: GroupPolicyAll, Members: members("search-a")}, "invalid resource reference"},
+		{"quorum of every member", HealthGroup{Policy: GroupPolicyQuorum, Quorum: 3, Members: three}, ""},
+		{"unreachable quorum", HealthGroup{Policy: GroupPolicyQuorum, Quorum: 4, Members: three}, "quorum 4 is larger than the 3 members"},
+		{"unreachable threshold", HealthGroup{Policy: GroupPolicyWeighted, Threshold: 1.5, Members: three}, "threshold 1.5 is above 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.group.Validate()
+			if (tt.err == "" && err != nil) || (tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err))) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -1,84 +1,64 @@
 package helpers
 
 import (
-	"encoding/json"
+	"os"
 	"reflect"
 	"runtime"
 	"strings"
-
-	"github.com/allegro/bigcache/v3"
+	"time"
 )
 
 type ScrapeConfiguration struct {
-	WatchedSecrets    []WatchedResource `yaml:"watched-secrets"`
-	WatchedConfigMaps []WatchedResource `yaml:"watched-configmaps"`
+	WatchedSecrets         []WatchedResource       `yaml:"watched-secrets" json:"watched-secrets,omitempty"`
+	WatchedConfigMaps      []WatchedResource       `yaml:"watched-configmaps" json:"watched-configmaps,omitempty"`
+	WatchedCustomResources []WatchedCustomResource `yaml:"watched-custom-resources" json:"watched-custom-resources,omitempty"`
+	HealthExpressions      map[string]string       `yaml:"health-expressions" json:"health-expressions,omitempty"` // cel expression keyed by kind
+	HealthGroups           map[string]HealthGroup  `yaml:"health-groups" json:"health-groups,omitempty"`           // keyed by group name
+	Dependencies           map[string][]string     `yaml:"dependencies" json:"dependencies,omitempty"`             // dependency references keyed by the dependent reference
 }
 
 type WatchedResource struct {
-	Name      string `yaml:"name"`
-	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name" json:"name"`
+	Namespace string `yaml:"namespace" json:"namespace"`
+	Optional  bool   `yaml:"optional" json:"optional,omitempty"` // a missing optional resource is not reported unhealthy
 }
 
-func LogMsg(args ...string) string {
-	return strings.Join(args, "")
+// WatchedCustomResource is a group/version/resource evaluated through the dynamic client against a set of rules
+type WatchedCustomResource struct {
+	Group         string       `yaml:"group" json:"group,omitempty"`
+	Version       string       `yaml:"version" json:"version,omitempty"`
+	Resource      string       `yaml:"resource" json:"resource,omitempty"`
+	Namespace     string       `yaml:"namespace" json:"namespace,omitempty"`         // optional, all namespaces when empty
+	LabelSelector string       `yaml:"labelSelector" json:"labelSelector,omitempty"` // optional
+	Rules         []HealthRule `yaml:"rules" json:"rules,omitempty"`
 }
 
-func GetFn(i interface{}) string {
-	return runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
+// HealthRule is either a status condition check (condition/status) or a jsonpath check (jsonPath/equals)
+type HealthRule struct {
+	Condition string `yaml:"condition" json:"condition,omitempty"`
+	Status    string `yaml:"status" json:"status,omitempty"` // defaults to "True"
+	JSONPath  string `yaml:"jsonPath" json:"jsonPath,omitempty"`
+	Equals    string `yaml:"equals" json:"equals,omitempty"`
+	State     string `yaml:"state" json:"state,omitempty"` // state reported when the rule fails, defaults to unavailable
 }
 
-// Helper function to serialize array and set it to cache
-func SetArray(cache *bigcache.BigCache, key string, arr []string) error {
-	// Serialize the array to JSON
-	data, err := json.Marshal(arr)
-	if err != nil {
-		return err
-	}
-	// Set serialized data to cache
-	return cache.Set(key, data)
+func LogMsg(args ...string) string {
+	return strings.Join(args, "")
 }
 
-// Helper function to get and deserialize the array from cache
-func GetArray(cache *bigcache.BigCache, key string) ([]string, error) {
-	// Get the data from cache
-	entry, err := cache.Get(key)
-	if err != nil {
-		return nil, err
+// GetEnvDuration reads a duration (eg: 5m, 90s) from the environment, falls back to def when unset or invalid
+func GetEnvDuration(key string, def time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
 	}
-	// Deserialize the data back to array
-	var arr []string
-	err = json.Unmarshal(entry, &arr)
+	d, err := time.ParseDuration(v)
 	if err != nil {
-		return nil, err
+		return def
 	}
-	return arr, nil
+	return d
 }
 
-// Add an item to the cached array
-func AddToArray(cache *bigcache.BigCache, key string, value string) error {
-	// Get the current array
-	arr, err := GetArray(cache, key)
-	if err != nil {
-		// Initialize empty array if key doesn't exist
-		arr = []string{}
-	}
-	// Append new value to array
-	arr = append(arr, value)
-	// Save updated array back to cache
-	return SetArray(cache, key, arr)
-}
-
-// Remove the last item from the cached array
-func PopFromArray(cache *bigcache.BigCache, key string) error {
-	// Get the current array
-	arr, err := GetArray(cache, key)
-	if err != nil {
-		return err
-	}
-	// Ensure the array is not empty before popping
-	if len(arr) > 0 {
-		arr = arr[:len(arr)-1] // Remove the last element
-	}
-	// Save the updated array back to cache
-	return SetArray(cache, key, arr)
+func GetFn(i interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
 }
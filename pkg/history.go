@@ -0,0 +1,145 @@
+package helpers
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Transition is a state change of a monitored resource, healthy resources have no status record
+type Transition struct {
+	Key       string            `json:"key"`
+	Cluster   string            `json:"cluster,omitempty"`
+	Kind      string            `json:"kind"`
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	From      string            `json:"from"`
+	To        string            `json:"to"`
+	Reason    string            `json:"reason,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// Record is the status record the transition led to, used to match filters
+func (t Transition) Record() StatusRecord {
+	return StatusRecord{Cluster: t.Cluster, Kind: t.Kind, Namespace: t.Namespace, Name: t.Name, State: t.To, Reason: t.Reason, Labels: t.Labels}
+}
+
+// number of transitions kept per resource, oldest are dropped first
+var historySize = envInt("HISTORY_SIZE", 100)
+
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+// History is a bounded ring buffer of transitions
+type History struct {
+	entries []Transition
+	next    int
+	dropped bool // older transitions were overwritten, the history no longer goes back to the first one
+}
+
+func (h *History) Add(t Transition) {
+	if len(h.entries) < historySize {
+		h.entries = append(h.entries, t)
+		return
+	}
+	h.entries[h.next] = t
+	h.next = (h.next + 1) % len(h.entries)
+	h.dropped = true
+}
+
+// latest returns the timestamp of the newest transition, zero when there is none
+func (h *History) latest() time.Time {
+	if len(h.entries) == 0 {
+		return time.Time{}
+	}
+	return h.entries[(h.next+len(h.entries)-1)%len(h.entries)].Timestamp
+}
+
+// List returns the transitions oldest first
+func (h *History) List() []Transition {
+	list := make([]Transition, 0, len(h.entries))
+	list = append(list, h.entries[h.next:]...)
+	return append(list, h.entries[:h.next]...)
+}
+
+// recordTransition appends to the key history when the state changed, callers hold kvs.mu
+func (kvs *KeyValueStore) recordTransition(key string, from string, to StatusRecord, at time.Time) {
+	if from == to.State {
+		return
+	}
+	h, ok := kvs.history[key]
+	if !ok {
+		h = &History{}
+		kvs.history[key] = h
+	}
+	t := Transition{Key: key, Cluster: to.Cluster, Kind: to.Kind, Namespace: to.Namespace, Name: to.Name, Labels: to.Labels, From: from, To: to.State, Reason: to.Reason, Timestamp: at}
+	h.Add(t)
+	kvs.publish(t)
+}
+
+// History returns the transitions matching the filter that happened after since, oldest first
+func (kvs *KeyValueStore) History(filter StatusFilter, since time.Time) []Transition {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	var transitions []Transition
+	for _, h := range kvs.history {
+		for _, t := range h.List() {
+			if t.Timestamp.Before(since) {
+				continue
+			}
+			if filter.Matches(t.Record()) {
+				transitions = append(transitions, t)
+			}
+		}
+	}
+	sort.Slice(transitions, func(i, j int) bool { return transitions[i].Timestamp.Before(transitions[j].Timestamp) })
+	return transitions
+}
+
+// allHistory copies every history oldest first, keyed by status key
+func (kvs *KeyValueStore) allHistory() map[string][]Transition {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	all := make(map[string][]Transition, len(kvs.history))
+	for key, h := range kvs.history {
+		all[key] = h.List()
+	}
+	return all
+}
+
+// truncatedSince returns the timestamp of the oldest transition kept by the histories which dropped older ones,
+// keyed by status key
+func (kvs *KeyValueStore) truncatedSince() map[string]time.Time {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	truncated := make(map[string]time.Time)
+	for key, h := range kvs.history {
+		if h.dropped && len(h.entries) > 0 {
+			truncated[key] = h.entries[h.next].Timestamp
+		}
+	}
+	return truncated
+}
+
+// restoreHistory replaces the histories, limit is the number of transitions per resource the snapshot was trimmed to
+func (kvs *KeyValueStore) restoreHistory(all map[string][]Transition, limit int) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.history = make(map[string]*History, len(all))
+	for key, transitions := range all {
+		h := &History{}
+		for _, t := range transitions {
+			h.Add(t)
+		}
+		// a full history may have dropped transitions before the snapshot was taken
+		h.dropped = h.dropped || len(transitions) >= historySize || (limit > 0 && len(transitions) >= limit)
+		kvs.history[key] = h
+	}
+}
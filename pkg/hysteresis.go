@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	// consecutive failed evaluations before a healthy resource is reported
+	failureThreshold = envInt("HEALTH_FAILURE_THRESHOLD", 1)
+	// consecutive passed evaluations before a reported resource is cleared
+	successThreshold = envInt("HEALTH_SUCCESS_THRESHOLD", 1)
+	// transitions within flapWindow which turn the resource into flapping, 0 disables flap detection
+	flapThreshold = envInt("FLAP_THRESHOLD", 0)
+	flapWindow    = GetEnvDuration("FLAP_WINDOW", 10*time.Minute)
+)
+
+type streak struct {
+	failures int
+	passes   int
+}
+
+// Report commits an evaluation through the hysteresis: a healthy resource is only reported after failureThreshold
+// failed evaluations, a reported one is only cleared after successThreshold passed evaluations, and a resource
+// changing state more than flapThreshold times within flapWindow is held as flapping until it settles down
+func (kvs *KeyValueStore) Report(key string, record StatusRecord) error {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	s := kvs.streaks[key]
+	if record.State == StateHealthy {
+		s.passes, s.failures = s.passes+1, 0
+	} else {
+		s.failures, s.passes = s.failures+1, 0
+	}
+	kvs.streaks[key] = s
+
+	current, reported := kvs.current(key)
+	if flapThreshold > 0 {
+		if changes := kvs.transitionsSince(key, record.CheckedAt.Add(-flapWindow), record.CheckedAt); changes >= flapThreshold || (current.State == StateFlapping && changes > 1) {
+			flapping := record
+			flapping.State = StateFlapping
+			flapping.Reason = fmt.Sprintf("%d state changes in %s, last evaluation: %s", changes, flapWindow, record.State)
+			if record.Reason != "" {
+				flapping.Reason = LogMsg(flapping.Reason, ", ", record.Reason)
+			}
+			return kvs.setStatus(key, flapping)
+		}
+	}
+	if record.State == StateHealthy {
+		if !reported {
+			return nil
+		}
+		if s.passes < successThreshold {
+			// keep the current verdict alive until enough evaluations passed
+			current.CheckedAt = record.CheckedAt
+			return kvs.setStatus(key, current)
+		}
+		return kvs.delete(key, record.CheckedAt)
+	}
+	if !reported && s.failures < failureThreshold {
+		return nil
+	}
+	return kvs.setStatus(key, record)
+}
+
+// transitionsSince counts the state changes of the key between since and the evaluation at until. the switches into
+// flapping are not counted, they would keep the resource flapping on their own. callers hold kvs.mu
+func (kvs *KeyValueStore) transitionsSince(key string, since, until time.Time) int {
+	h, ok := kvs.history[key]
+	if !ok {
+		return 0
+	}
+	count := 0
+	for _, t := range h.entries {
+		if t.To != StateFlapping && t.Timestamp.After(since) && t.Timestamp.Before(until) {
+			count++
+		}
+	}
+	return count
+}
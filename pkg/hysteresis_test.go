@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+// evaluation of the test resource at the given minute
+type evaluation struct {
+	minute int
+	state  string
+	want   string // stored state after the evaluation, healthy when nothing is reported
+}
+
+func replay(t *testing.T, evaluations []evaluation) *KeyValueStore {
+	t.Helper()
+	kvs := NewKeyValueStore()
+	for _, e := range evaluations {
+		record := StatusRecord{Kind: "deployment", Namespace: "payments", Name: "api", State: e.state, CheckedAt: at(e.minute)}
+		if err := kvs.Report("deployment.apps/payments/api", record); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := kvs.current("deployment.apps/payments/api"); got.State != e.want {
+			t.Fatalf("minute %d: %s evaluation stored %s, want %s", e.minute, e.state, got.State, e.want)
+		}
+	}
+	return kvs
+}
+
+func setThresholds(t *testing.T, failures, passes, flaps int) {
+	t.Helper()
+	previous := []int{failureThreshold, successThreshold, flapThreshold}
+	t.Cleanup(func() { failureThreshold, successThreshold, flapThreshold = previous[0], previous[1], previous[2] })
+	failureThreshold, successThreshold, flapThreshold = failures, passes, flaps
+}
+
+func TestHysteresisThresholds(t *testing.T) {
+	setThresholds(t, 2, 2, 0)
+	replay(t, []evaluation{
+		{0, StateUnavailable, StateHealthy},
+		{1, StateHealthy, StateHealthy}, // a pass resets the failures
+		{2, StateUnavailable, StateHealthy},
+		{3, StateUnavailable, StateUnavailable},
+		{4, StateDegraded, StateDegraded}, // already reported, changes right away
+		{5, StateHealthy, StateDegraded},
+		{6, StateUnavailable, StateUnavailable},
+		{7, StateHealthy, StateUnavailable},
+		{8, StateHealthy, StateHealthy},
+	})
+}
+
+func TestFlapDetection(t *testing.T) {
+	setThresholds(t, 1, 1, 3)
+	defer func(window time.Duration) { flapWindow = window }(flapWindow)
+	flapWindow = 10 * time.Minute
+	kvs := replay(t, []evaluation{
+		{1, StateUnavailable, StateUnavailable},
+		{2, StateHealthy, StateHealthy},
+		{3, StateUnavailable, StateUnavailable},
+		{4, StateHealthy, StateFlapping}, // the fourth change within the window
+		{5, StateUnavailable, StateFlapping},
+		{6, StateHealthy, StateFlapping},
+		{11, StateHealthy, StateFlapping}, // minutes 2 and 3 are still within the window
+		// only minute 3 is left, the switch into flapping at minute 4 does not count
+		{12, StateHealthy, StateHealthy},
+	})
+	if changes := kvs.transitionsSince("deployment.apps/payments/api", at(0), at(4)); changes != 3 {
+		t.Fatalf("%d changes before minute 4, want 3", changes)
+	}
+}
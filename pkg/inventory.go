@@ -0,0 +1,107 @@
+package helpers
+
+import (
+	"sort"
+	"time"
+)
+
+// resources not evaluated for this long are no longer considered monitored
+const inventoryTTL = 10 * time.Minute
+
+// how long the inventory entry, history and streak of a resource outlive its last evaluation, the longest slo window
+// so that a deleted resource still has its timeline and a restored snapshot keeps its first seen times
+var historyRetention = retention(SLOWindows())
+
+func retention(windows []time.Duration) time.Duration {
+	longest := inventoryTTL
+	for _, window := range windows {
+		if window > longest {
+			longest = window
+		}
+	}
+	return longest
+}
+
+// InventoryEntry is a resource evaluated at least once, healthy or not
+type InventoryEntry struct {
+	Key           string            `json:"key"`
+	Cluster       string            `json:"cluster,omitempty"`
+	Kind          string            `json:"kind"`
+	Namespace     string            `json:"namespace"`
+	Name          string            `json:"name"`
+	SLOTarget     float64           `json:"sloTarget,omitempty"`
+	Replicas      *Replicas         `json:"replicas,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	SilencedUntil *time.Time        `json:"silencedUntil,omitempty"` // silence-until annotation, kept while a watched secret or configmap is missing
+	FirstSeen     time.Time         `json:"firstSeen"`
+	LastSeen      time.Time         `json:"lastSeen"`
+}
+
+// how often Observe drops the resources which expired from the inventory
+const inventoryPruneInterval = time.Minute
+
+// Observe records that the resource behind the key was evaluated
+func (kvs *KeyValueStore) Observe(key string, record StatusRecord) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	now := time.Now()
+	if now.Sub(kvs.lastPrune) >= inventoryPruneInterval {
+		kvs.pruneInventory(now)
+	}
+	entry, ok := kvs.inventory[key]
+	if !ok {
+		entry = InventoryEntry{Key: key, FirstSeen: now}
+	}
+	entry.Cluster, entry.Kind, entry.Namespace, entry.Name = record.Cluster, record.Kind, record.Namespace, record.Name
+	entry.SLOTarget = record.SLOTarget
+	entry.Replicas, entry.Labels, entry.SilencedUntil = record.Replicas, record.Labels, record.SilencedUntil
+	entry.LastSeen = now
+	kvs.inventory[key] = entry
+}
+
+// LastObserved returns the inventory entry of the resource behind the key as it was last observed, false when unknown
+func (kvs *KeyValueStore) LastObserved(key string) (InventoryEntry, bool) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	entry, ok := kvs.inventory[key]
+	return entry, ok
+}
+
+// Inventory returns the monitored resources matching the filter, sorted by key
+func (kvs *KeyValueStore) Inventory(filter StatusFilter) []InventoryEntry {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.pruneInventory(time.Now())
+	var entries []InventoryEntry
+	for _, entry := range kvs.inventory {
+		if time.Since(entry.LastSeen) > inventoryTTL {
+			continue
+		}
+		if filter.Matches(StatusRecord{Cluster: entry.Cluster, Kind: entry.Kind, Namespace: entry.Namespace, Name: entry.Name, Labels: entry.Labels}) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+// pruneInventory forgets the resources not evaluated within historyRetention, eg: deleted ones, along with their streaks.
+// a history outlives its resource until its newest transition is older than historyRetention. callers hold kvs.mu
+func (kvs *KeyValueStore) pruneInventory(now time.Time) {
+	kvs.lastPrune = now
+	for key, entry := range kvs.inventory {
+		if now.Sub(entry.LastSeen) > historyRetention {
+			delete(kvs.inventory, key)
+		}
+	}
+	for key, h := range kvs.history {
+		if _, ok := kvs.inventory[key]; !ok && now.Sub(h.latest()) > historyRetention {
+			delete(kvs.history, key)
+		}
+	}
+	for key := range kvs.streaks {
+		if _, ok := kvs.inventory[key]; !ok {
+			delete(kvs.streaks, key)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+// a deleted resource leaves the inventory after inventoryTTL but keeps its timeline for historyRetention
+func TestInventoryPrunesExpiredResources(t *testing.T) {
+	kvs := NewKeyValueStore()
+	now := time.Now()
+	for _, key := range []string{"deployment.apps/payments/api", "deployment.apps/payments/gone", "deployment.apps/payments/old"} {
+		record := StatusRecord{Kind: "deployment", Namespace: "payments", Name: key, State: StateUnavailable, CheckedAt: now}
+		kvs.Observe(key, record)
+		if err := kvs.Report(key, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	age := func(key string, by time.Duration) {
+		entry := kvs.inventory[key]
+		entry.LastSeen = now.Add(-by)
+		kvs.inventory[key] = entry
+		kvs.history[key].entries[0].Timestamp = now.Add(-by)
+	}
+	age("deployment.apps/payments/gone", inventoryTTL+time.Minute)
+	age("deployment.apps/payments/old", historyRetention+time.Minute)
+	kvs.lastPrune = now.Add(-inventoryPruneInterval)
+	kvs.Observe("deployment.apps/payments/api", StatusRecord{Kind: "deployment", Namespace: "payments", Name: "api"})
+
+	for _, entry := range kvs.Inventory(StatusFilter{}) {
+		if entry.Key != "deployment.apps/payments/api" {
+			t.Fatalf("%s is still monitored", entry.Key)
+		}
+	}
+	if _, ok := kvs.history["deployment.apps/payments/gone"]; !ok {
+		t.Fatal("history of a resource deleted within the retention dropped")
+	}
+	if _, ok := kvs.history["deployment.apps/payments/old"]; ok {
+		t.Fatal("history older than the retention kept")
+	}
+	if _, ok := kvs.streaks["deployment.apps/payments/old"]; ok {
+		t.Fatal("streak of a resource past the retention kept")
+	}
+}
+
+// the first evaluation after a restart must not drop the restored timeline, however long the process was down
+func TestRestoreOldSnapshotKeepsHistory(t *testing.T) {
+	now := time.Now()
+	key := "deployment.apps/payments/api"
+	firstSeen := now.Add(-10 * 24 * time.Hour)
+	snapshot := Snapshot{
+		History: map[string][]Transition{key: {
+			{Key: key, Kind: "deployment", Namespace: "payments", Name: "api", From: StateHealthy, To: StateUnavailable, Timestamp: now.Add(-3 * time.Hour)},
+			{Key: key, Kind: "deployment", Namespace: "payments", Name: "api", From: StateUnavailable, To: StateHealthy, Timestamp: now.Add(-2 * time.Hour)},
+		}},
+		Inventory: map[string]InventoryEntry{key: {Key: key, Kind: "deployment", Namespace: "payments", Name: "api", FirstSeen: firstSeen, LastSeen: now.Add(-time.Hour)}},
+		TakenAt:   now.Add(-time.Hour),
+	}
+	kvs := NewKeyValueStore()
+	if err := kvs.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	kvs.Observe(key, StatusRecord{Kind: "deployment", Namespace: "payments", Name: "api", State: StateHealthy})
+	if n := len(kvs.history[key].List()); n != 2 {
+		t.Fatalf("%d transitions after the first evaluation, want the 2 restored", n)
+	}
+	if entry := kvs.inventory[key]; !entry.FirstSeen.Equal(firstSeen) {
+		t.Fatalf("first seen %s, want the restored %s", entry.FirstSeen, firstSeen)
+	}
+}
+
+func TestLastObserved(t *testing.T) {
+	kvs := NewKeyValueStore()
+	if _, ok := kvs.LastObserved("secrets.shop/stripe-key"); ok {
+		t.Fatal("unknown resource observed")
+	}
+	until := time.Now().Add(time.Hour)
+	kvs.Observe("secrets.shop/stripe-key", StatusRecord{Kind: "secrets", Namespace: "shop", Name: "stripe-key", Labels: map[string]string{"team": "payments"}, SilencedUntil: &until})
+	entry, ok := kvs.LastObserved("secrets.shop/stripe-key")
+	if !ok || entry.Labels["team"] != "payments" || entry.SilencedUntil == nil || !entry.SilencedUntil.Equal(until) {
+		t.Fatalf("entry %+v, want the labels and silence it was observed with", entry)
+	}
+}
@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"sync"
 	"time"
@@ -11,10 +12,21 @@ import (
 )
 
 type KeyValueStore struct {
-	cache   *bigcache.BigCache
-	gocache *cache.Cache
-	keys    map[string][]byte
-	mu      sync.Mutex // Mutex for protecting access to keys
+	cache     *bigcache.BigCache
+	gocache   *cache.Cache
+	keys      map[string][]byte
+	history   map[string]*History       // transitions keyed by status key
+	inventory map[string]InventoryEntry // every evaluated resource keyed by status key
+	streaks   map[string]streak         // consecutive evaluation results keyed by status key
+	silences  map[string]Silence        // keyed by silence id
+	lastPrune time.Time                 // last time expired inventory entries were dropped
+	mu        sync.Mutex                // Mutex for protecting access to keys, history and inventory
+
+	events         []Event // latest state changes, oldest first
+	epoch          uint64  // first event id of this process, ids below it were handed out by an earlier one
+	lastEventID    uint64
+	subscribers    map[int]chan Event
+	nextSubscriber int
 }
 
 func NewKeyValueStore() *KeyValueStore {
@@ -29,7 +41,8 @@ func NewKeyValueStore() *KeyValueStore {
 	}
 	bigcache, _ := bigcache.New(context.Background(), cacheConfig)
 	gocache := cache.New(5*time.Minute, 10*time.Minute)
-	return &KeyValueStore{cache: bigcache, gocache: gocache, keys: make(map[string][]byte)}
+	epoch := newEpoch()
+	return &KeyValueStore{cache: bigcache, gocache: gocache, keys: make(map[string][]byte), history: make(map[string]*History), inventory: make(map[string]InventoryEntry), streaks: make(map[string]streak), silences: make(map[string]Silence), subscribers: make(map[int]chan Event), epoch: epoch, lastEventID: epoch}
 }
 
 func (kvs *KeyValueStore) GoCacheSet(key string, value interface{}) error {
@@ -59,13 +72,70 @@ func (kvs *KeyValueStore) Set(key string, value []byte) error {
 	return kvs.cache.Set(key, value)
 }
 
+// SetStatus serialises the status record and stores it against the key, since is carried over while the state is unchanged
+func (kvs *KeyValueStore) SetStatus(key string, record StatusRecord) error {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	return kvs.setStatus(key, record)
+}
+
+// setStatus is SetStatus for callers holding kvs.mu
+func (kvs *KeyValueStore) setStatus(key string, record StatusRecord) error {
+	previous, _ := kvs.current(key)
+	if previous.State == record.State && !previous.Since.IsZero() {
+		record.Since = previous.Since
+	} else if record.Since.IsZero() {
+		record.Since = record.CheckedAt
+	}
+	kvs.recordTransition(key, previous.State, record, record.CheckedAt)
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	kvs.keys[key] = data
+	return kvs.cache.Set(key, data)
+}
+
 func (kvs *KeyValueStore) Delete(key string) error {
 	kvs.mu.Lock()         // Lock the mutex before modifying keys
 	defer kvs.mu.Unlock() // Ensure the mutex is unlocked after the function returns
+	return kvs.delete(key, time.Now())
+}
+
+// delete is Delete for callers holding kvs.mu, the recovery is recorded at the given evaluation time
+func (kvs *KeyValueStore) delete(key string, at time.Time) error {
+	if previous, ok := kvs.current(key); ok {
+		recovered := previous
+		recovered.State = StateHealthy
+		recovered.Reason = ""
+		kvs.recordTransition(key, previous.State, recovered, at)
+	}
 	delete(kvs.keys, key)
 	return kvs.cache.Delete(key)
 }
 
+// Forget clears the resource behind the key once it is no longer in scope, a reported state recovers and the
+// resource drops out of the inventory
+func (kvs *KeyValueStore) Forget(key string) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	if _, reported := kvs.current(key); reported {
+		kvs.delete(key, time.Now())
+	}
+	delete(kvs.streaks, key)
+	delete(kvs.inventory, key)
+}
+
+// current returns the stored record of the key, a healthy record when there is none. callers hold kvs.mu
+func (kvs *KeyValueStore) current(key string) (StatusRecord, bool) {
+	var record StatusRecord
+	value, ok := kvs.keys[key]
+	if !ok || json.Unmarshal(value, &record) != nil {
+		return StatusRecord{State: StateHealthy}, false
+	}
+	return record, true
+}
+
 func (kvs *KeyValueStore) LenAll() int {
 	kvs.mu.Lock()         // Lock the mutex for reading keys
 	defer kvs.mu.Unlock() // Ensure the mutex is unlocked after reading
@@ -74,17 +144,73 @@ func (kvs *KeyValueStore) LenAll() int {
 
 func (kvs *KeyValueStore) GetAll() (map[string]interface{}, error) {
 	allValues := make(map[string]interface{})
-	kvs.mu.Lock()         // Lock the mutex for reading keys
-	defer kvs.mu.Unlock() // Ensure the mutex is unlocked after reading
+	for key, record := range kvs.GetAllStatus(StatusFilter{}) {
+		allValues[key] = record
+	}
+	return allValues, nil
+}
+
+// GetAllStatus returns the status records matching the filter, marked silenced when a silence covers them and
+// impacted when one of their dependencies is unhealthy
+func (kvs *KeyValueStore) GetAllStatus(filter StatusFilter) map[string]StatusRecord {
+	records := make(map[string]StatusRecord)
+	kvs.mu.Lock()
+	kvs.pruneSilences()
 	for key := range kvs.keys {
 		value, err := kvs.cache.Get(key)
-		if err == nil {
-			allValues[key] = string(value)
-		} else {
-			delete(allValues, key) // Ensure the map has latest updates and remove the old ones
+		if err != nil {
+			delete(kvs.keys, key) // Ensure the map has latest updates and remove the old ones
 			// todo: best approach is to have a callback on key expiration to check and clean up the map but computation on map will be higher
 			// for now lazy delete is good.
+			continue
+		}
+		var record StatusRecord
+		if json.Unmarshal(value, &record) == nil {
+			records[key] = kvs.applySilences(record)
 		}
 	}
-	return allValues, nil
+	kvs.mu.Unlock()
+	// dependencies may fall outside of the filter, resolve them before filtering
+	applyDependencies(records)
+	for key, record := range records {
+		if !filter.Matches(record) {
+			delete(records, key)
+		}
+	}
+	return records
+}
+
+// ReplaceStatus replaces every status record with the given ones, used to restore a snapshot
+// the differences are published to the stream subscribers
+func (kvs *KeyValueStore) ReplaceStatus(records map[string]StatusRecord) error {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	for key := range kvs.keys {
+		if _, ok := records[key]; !ok {
+			if previous, ok := kvs.current(key); ok {
+				kvs.publish(Transition{Key: key, Cluster: previous.Cluster, Kind: previous.Kind, Namespace: previous.Namespace, Name: previous.Name, Labels: previous.Labels, From: previous.State, To: StateHealthy, Timestamp: time.Now()})
+			}
+			delete(kvs.keys, key)
+			kvs.cache.Delete(key)
+		}
+	}
+	for key, record := range records {
+		// a key the store does not hold yet was healthy
+		from := StateHealthy
+		if previous, ok := kvs.current(key); ok {
+			from = previous.State
+		}
+		if from != record.State {
+			kvs.publish(Transition{Key: key, Cluster: record.Cluster, Kind: record.Kind, Namespace: record.Namespace, Name: record.Name, Labels: record.Labels, From: from, To: record.State, Reason: record.Reason, Timestamp: record.CheckedAt})
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		kvs.keys[key] = data
+		if err := kvs.cache.Set(key, data); err != nil {
+			return err
+		}
+	}
+	return nil
 }
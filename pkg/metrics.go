@@ -0,0 +1,54 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `%s="%s"`, pairs[i], labelEscaper.Replace(pairs[i+1]))
+	}
+	return b.String()
+}
+
+// WriteMetrics renders the availability report in the prometheus text exposition format
+func WriteMetrics(w io.Writer, report AvailabilityReport) {
+	fmt.Fprintln(w, "# HELP k8sclustervitals_availability_ratio Share of the rolling window the resource was available.")
+	fmt.Fprintln(w, "# TYPE k8sclustervitals_availability_ratio gauge")
+	for _, r := range report.Resources {
+		for window, a := range r.Windows {
+			fmt.Fprintf(w, "k8sclustervitals_availability_ratio{%s} %g\n", labels("cluster", r.Cluster, "kind", r.Kind, "namespace", r.Namespace, "name", r.Name, "window", window), a.Availability/100)
+		}
+	}
+	fmt.Fprintln(w, "# HELP k8sclustervitals_slo_target_ratio Availability target declared with the k8sclustervitals.io/slo annotation.")
+	fmt.Fprintln(w, "# TYPE k8sclustervitals_slo_target_ratio gauge")
+	for _, r := range report.Resources {
+		if r.SLOTarget > 0 {
+			fmt.Fprintf(w, "k8sclustervitals_slo_target_ratio{%s} %g\n", labels("cluster", r.Cluster, "kind", r.Kind, "namespace", r.Namespace, "name", r.Name), r.SLOTarget/100)
+		}
+	}
+	fmt.Fprintln(w, "# HELP k8sclustervitals_error_budget_remaining_ratio Share of the error budget left over the rolling window.")
+	fmt.Fprintln(w, "# TYPE k8sclustervitals_error_budget_remaining_ratio gauge")
+	for _, r := range report.Resources {
+		for window, a := range r.Windows {
+			if a.ErrorBudgetRemaining != nil {
+				fmt.Fprintf(w, "k8sclustervitals_error_budget_remaining_ratio{%s} %g\n", labels("cluster", r.Cluster, "kind", r.Kind, "namespace", r.Namespace, "name", r.Name, "window", window), *a.ErrorBudgetRemaining/100)
+			}
+		}
+	}
+	fmt.Fprintln(w, "# HELP k8sclustervitals_cluster_availability_ratio Share of the rolling window no monitored resource of the cluster was unavailable.")
+	fmt.Fprintln(w, "# TYPE k8sclustervitals_cluster_availability_ratio gauge")
+	for _, c := range report.Clusters {
+		for window, a := range c.Windows {
+			fmt.Fprintf(w, "k8sclustervitals_cluster_availability_ratio{%s} %g\n", labels("cluster", c.Cluster, "window", window), a.Availability/100)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Snapshot is the persisted state of the store, restored on startup
+type Snapshot struct {
+	Status    map[string]StatusRecord   `json:"status"`
+	History   map[string][]Transition   `json:"history,omitempty"`
+	Inventory map[string]InventoryEntry `json:"inventory,omitempty"`
+	Silences  map[string]Silence        `json:"silences,omitempty"`
+	Clusters  map[string]bool           `json:"clusters,omitempty"` // readiness of the monitored clusters, replicated to followers
+	TakenAt   time.Time                 `json:"takenAt"`
+	// transitions kept per resource when the history was trimmed to fit the backend, 0 when complete
+	HistoryLimit int `json:"historyLimit,omitempty"`
+}
+
+// WithHistoryLimit returns a copy of the snapshot keeping only the newest limit transitions of every resource
+func (s Snapshot) WithHistoryLimit(limit int) Snapshot {
+	history := make(map[string][]Transition, len(s.History))
+	for key, transitions := range s.History {
+		if len(transitions) > limit {
+			transitions = transitions[len(transitions)-limit:]
+		}
+		history[key] = transitions
+	}
+	s.History, s.HistoryLimit = history, limit
+	return s
+}
+
+// SnapshotBackend persists snapshots across restarts
+type SnapshotBackend interface {
+	Save(snapshot Snapshot) error
+	Load() (Snapshot, error)
+}
+
+var ErrNoSnapshot = errors.New("no snapshot found")
+
+// Snapshot captures the current status records
+func (kvs *KeyValueStore) Snapshot() Snapshot {
+	kvs.mu.Lock()
+	inventory := make(map[string]InventoryEntry, len(kvs.inventory))
+	for key, entry := range kvs.inventory {
+		inventory[key] = entry
+	}
+	silences := make(map[string]Silence, len(kvs.silences))
+	for id, silence := range kvs.silences {
+		silences[id] = silence
+	}
+	kvs.mu.Unlock()
+	return Snapshot{Status: kvs.GetAllStatus(StatusFilter{}), History: kvs.allHistory(), Inventory: inventory, Silences: silences, TakenAt: time.Now()}
+}
+
+// Restore replaces the current status records, history, inventory and silences with the snapshot ones
+func (kvs *KeyValueStore) Restore(snapshot Snapshot) error {
+	if snapshot.History != nil {
+		kvs.restoreHistory(snapshot.History, snapshot.HistoryLimit)
+	}
+	kvs.mu.Lock()
+	if snapshot.Inventory != nil {
+		kvs.inventory = snapshot.Inventory
+	}
+	if snapshot.Silences != nil {
+		kvs.silences = snapshot.Silences
+	}
+	kvs.mu.Unlock()
+	return kvs.ReplaceStatus(snapshot.Status)
+}
+
+var (
+	boltBucket = []byte("vitals")
+	boltKey    = []byte("snapshot")
+)
+
+// BoltBackend keeps the snapshot in a local BoltDB file
+type BoltBackend struct {
+	db *bolt.DB
+}
+
+func NewBoltBackend(path string) (*BoltBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltBackend{db: db}, nil
+}
+
+func (b *BoltBackend) Save(snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(boltKey, data)
+	})
+}
+
+func (b *BoltBackend) Load() (Snapshot, error) {
+	var snapshot Snapshot
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		if bucket == nil {
+			return ErrNoSnapshot
+		}
+		data := bucket.Get(boltKey)
+		if data == nil {
+			return ErrNoSnapshot
+		}
+		return json.Unmarshal(data, &snapshot)
+	})
+	return snapshot, err
+}
+
+func (b *BoltBackend) Close() error {
+	return b.db.Close()
+}
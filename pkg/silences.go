@@ -0,0 +1,121 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Silence mutes the resources matching its matchers until it ends, muted resources are still reported but left out
+// of the health verdict and of notifications
+type Silence struct {
+	ID       string       `json:"id"`
+	Matchers StatusFilter `json:"matchers"`
+	Author   string       `json:"author"`
+	Comment  string       `json:"comment,omitempty"`
+	StartsAt time.Time    `json:"startsAt"`
+	EndsAt   time.Time    `json:"endsAt"`
+}
+
+var ErrSilenceNotFound = errors.New("silence not found")
+
+// AddSilence registers a silence for the given duration and returns it with its id
+func (kvs *KeyValueStore) AddSilence(silence Silence, duration time.Duration) (Silence, error) {
+	m := silence.Matchers
+	if m.Cluster == "" && m.Kind == "" && m.Namespace == "" && m.Name == "" && m.LabelSelector == "" && m.State == "" {
+		return silence, errors.New("at least one matcher is required")
+	}
+	if err := silence.Matchers.Validate(); err != nil {
+		return silence, err
+	}
+	if duration <= 0 {
+		return silence, errors.New("duration must be positive")
+	}
+	if silence.Author == "" {
+		return silence, errors.New("author is required")
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return silence, err
+	}
+	silence.ID = hex.EncodeToString(id)
+	silence.StartsAt = time.Now()
+	silence.EndsAt = silence.StartsAt.Add(duration)
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.silences[silence.ID] = silence
+	return silence, nil
+}
+
+func (kvs *KeyValueStore) DeleteSilence(id string) error {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	if _, ok := kvs.silences[id]; !ok {
+		return ErrSilenceNotFound
+	}
+	delete(kvs.silences, id)
+	return nil
+}
+
+// Silences returns the active silences, ending first
+func (kvs *KeyValueStore) Silences() []Silence {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.pruneSilences()
+	silences := make([]Silence, 0, len(kvs.silences))
+	for _, silence := range kvs.silences {
+		silences = append(silences, silence)
+	}
+	sort.Slice(silences, func(i, j int) bool { return silences[i].EndsAt.Before(silences[j].EndsAt) })
+	return silences
+}
+
+// pruneSilences drops the silences which ended, callers hold kvs.mu
+func (kvs *KeyValueStore) pruneSilences() {
+	now := time.Now()
+	for id, silence := range kvs.silences {
+		if now.After(silence.EndsAt) {
+			delete(kvs.silences, id)
+		}
+	}
+}
+
+// applySilences marks the record silenced when its silence-until annotation or an active silence covers it, callers hold kvs.mu
+func (kvs *KeyValueStore) applySilences(record StatusRecord) StatusRecord {
+	now := time.Now()
+	record.Silenced = record.SilencedUntil != nil && now.Before(*record.SilencedUntil)
+	for _, silence := range kvs.silences {
+		if now.Before(silence.EndsAt) && silence.Matchers.Matches(record) {
+			record.Silenced = true
+			if record.SilencedUntil == nil || silence.EndsAt.After(*record.SilencedUntil) {
+				endsAt := silence.EndsAt
+				record.SilencedUntil = &endsAt
+			}
+		}
+	}
+	return record
+}
+
+// Silenced reports whether the resource of the transition is covered by its silence-until annotation or an active silence
+func (kvs *KeyValueStore) Silenced(t Transition) bool {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	record, ok := kvs.current(t.Key)
+	if !ok {
+		record = t.Record() // deleted, only the silences can cover it
+	}
+	return kvs.applySilences(record).Silenced
+}
+
+// Unsilenced counts the records which are not silenced
+func Unsilenced(records map[string]StatusRecord) int {
+	count := 0
+	for _, record := range records {
+		if !record.Silenced {
+			count++
+		}
+	}
+	return count
+}
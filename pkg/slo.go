@@ -0,0 +1,225 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WindowAvailability is the availability of a resource over a rolling window
+type WindowAvailability struct {
+	Availability         float64  `json:"availability"`                   // percent of the observed time the resource was available
+	ErrorBudgetRemaining *float64 `json:"errorBudgetRemaining,omitempty"` // percent of the error budget left, only with a slo target
+	Observed             string   `json:"observed"`                       // part of the window covered by monitoring
+	Truncated            bool     `json:"truncated,omitempty"`            // the history dropped older transitions, observed starts at the oldest one kept
+}
+
+// ResourceAvailability is the rolling availability of a monitored resource
+type ResourceAvailability struct {
+	InventoryEntry
+	Windows map[string]WindowAvailability `json:"windows"`
+}
+
+// ClusterAvailability is the share of time no resource of the cluster was unavailable
+type ClusterAvailability struct {
+	Cluster string                        `json:"cluster"`
+	Windows map[string]WindowAvailability `json:"windows"`
+}
+
+type AvailabilityReport struct {
+	Resources []ResourceAvailability `json:"resources"`
+	Clusters  []ClusterAvailability  `json:"clusters"`
+}
+
+// SLOWindows returns the rolling windows from SLO_WINDOWS, defaults to 1h, 24h and 30 days
+func SLOWindows() []time.Duration {
+	var windows []time.Duration
+	for _, v := range strings.Split(os.Getenv("SLO_WINDOWS"), ",") {
+		if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil && d > 0 {
+			windows = append(windows, d)
+		}
+	}
+	if len(windows) == 0 {
+		windows = []time.Duration{time.Hour, 24 * time.Hour, 30 * 24 * time.Hour}
+	}
+	return windows
+}
+
+// WindowName renders a window the way it is keyed in reports, eg: 1h, 24h, 30d
+func WindowName(window time.Duration) string {
+	if window > 24*time.Hour && window%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", window/(24*time.Hour))
+	}
+	name := window.String()
+	name = strings.TrimSuffix(name, "m0s")
+	if strings.HasSuffix(name, "h0") {
+		return strings.TrimSuffix(name, "0")
+	}
+	if !strings.HasSuffix(name, "s") {
+		name += "m"
+	}
+	return name
+}
+
+// a flapping resource keeps serving between its failed evaluations, its time only counts as downtime with
+// SLO_FLAPPING_AS_DOWNTIME=true
+var flappingAsDowntime = os.Getenv("SLO_FLAPPING_AS_DOWNTIME") == "true"
+
+// available is false for the states counted against availability, a degraded resource still serves
+func available(state string) bool {
+	return state == StateHealthy || state == StateDegraded || (state == StateFlapping && !flappingAsDowntime)
+}
+
+type interval struct {
+	start, end time.Time
+}
+
+// downtime returns the intervals the resource spent unavailable between from and to, the state before the first
+// transition is its from state
+func downtime(transitions []Transition, from, to time.Time) []interval {
+	state := StateHealthy
+	if len(transitions) > 0 && transitions[0].From != "" {
+		state = transitions[0].From
+	}
+	var intervals []interval
+	var downSince time.Time
+	for _, t := range transitions {
+		if t.Timestamp.After(to) {
+			break
+		}
+		if t.Timestamp.Before(from) {
+			state = t.To
+			continue
+		}
+		if available(state) && !available(t.To) {
+			downSince = t.Timestamp
+		} else if !available(state) && available(t.To) {
+			start := downSince
+			if start.IsZero() {
+				start = from
+			}
+			intervals = append(intervals, interval{start, t.Timestamp})
+			downSince = time.Time{}
+		}
+		state = t.To
+	}
+	if !available(state) {
+		start := downSince
+		if start.IsZero() {
+			start = from
+		}
+		intervals = append(intervals, interval{start, to})
+	}
+	return intervals
+}
+
+func total(intervals []interval) time.Duration {
+	var d time.Duration
+	for _, i := range intervals {
+		d += i.end.Sub(i.start)
+	}
+	return d
+}
+
+// merge returns the union of the intervals
+func merge(intervals []interval) []interval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start.Before(intervals[j].start) })
+	var merged []interval
+	for _, i := range intervals {
+		if n := len(merged); n > 0 && !i.start.After(merged[n-1].end) {
+			if i.end.After(merged[n-1].end) {
+				merged[n-1].end = i.end
+			}
+			continue
+		}
+		merged = append(merged, i)
+	}
+	return merged
+}
+
+// clip returns the parts of the intervals after from
+func clip(intervals []interval, from time.Time) []interval {
+	var clipped []interval
+	for _, i := range intervals {
+		if !i.end.After(from) {
+			continue
+		}
+		if i.start.Before(from) {
+			i.start = from
+		}
+		clipped = append(clipped, i)
+	}
+	return clipped
+}
+
+func windowAvailability(down []interval, observed time.Duration, target float64, truncated bool) WindowAvailability {
+	w := WindowAvailability{Availability: 100, Observed: observed.Round(time.Second).String(), Truncated: truncated}
+	if observed > 0 {
+		w.Availability = 100 * (1 - float64(total(down))/float64(observed))
+	}
+	if target > 0 && target < 100 {
+		budget := 100 * (1 - (100-w.Availability)/(100-target))
+		w.ErrorBudgetRemaining = &budget
+	}
+	return w
+}
+
+// Availability computes the rolling availability of every monitored resource matching the filter and of their clusters
+func (kvs *KeyValueStore) Availability(filter StatusFilter, windows []time.Duration) AvailabilityReport {
+	now := time.Now()
+	inventory := kvs.Inventory(filter)
+	history := kvs.allHistory()
+	truncated := kvs.truncatedSince()
+	report := AvailabilityReport{Resources: []ResourceAvailability{}, Clusters: []ClusterAvailability{}}
+	clusterDown := make(map[string]map[string][]interval)
+	clusterFirstSeen := make(map[string]time.Time)
+	clusterTruncated := make(map[string]time.Time) // latest start of a truncated history, the cluster is only observed after it
+	for _, entry := range inventory {
+		resource := ResourceAvailability{InventoryEntry: entry, Windows: make(map[string]WindowAvailability)}
+		if first, ok := clusterFirstSeen[entry.Cluster]; !ok || entry.FirstSeen.Before(first) {
+			clusterFirstSeen[entry.Cluster] = entry.FirstSeen
+		}
+		if clusterDown[entry.Cluster] == nil {
+			clusterDown[entry.Cluster] = make(map[string][]interval)
+		}
+		since, isTruncated := truncated[entry.Key]
+		if isTruncated && since.After(clusterTruncated[entry.Cluster]) {
+			clusterTruncated[entry.Cluster] = since
+		}
+		for _, window := range windows {
+			from := now.Add(-window)
+			if entry.FirstSeen.After(from) {
+				from = entry.FirstSeen
+			}
+			windowTruncated := isTruncated && since.After(from)
+			if windowTruncated {
+				from = since // the transitions before it are gone, do not count that time as available
+			}
+			down := downtime(history[entry.Key], from, now)
+			name := WindowName(window)
+			resource.Windows[name] = windowAvailability(down, now.Sub(from), entry.SLOTarget, windowTruncated)
+			clusterDown[entry.Cluster][name] = append(clusterDown[entry.Cluster][name], down...)
+		}
+		report.Resources = append(report.Resources, resource)
+	}
+	for cluster, down := range clusterDown {
+		c := ClusterAvailability{Cluster: cluster, Windows: make(map[string]WindowAvailability)}
+		for _, window := range windows {
+			from := now.Add(-window)
+			if clusterFirstSeen[cluster].After(from) {
+				from = clusterFirstSeen[cluster]
+			}
+			windowTruncated := clusterTruncated[cluster].After(from)
+			if windowTruncated {
+				from = clusterTruncated[cluster]
+			}
+			name := WindowName(window)
+			c.Windows[name] = windowAvailability(merge(clip(down[name], from)), now.Sub(from), 0, windowTruncated)
+		}
+		report.Clusters = append(report.Clusters, c)
+	}
+	sort.Slice(report.Clusters, func(i, j int) bool { return report.Clusters[i].Cluster < report.Clusters[j].Cluster })
+	return report
+}
@@ -0,0 +1,181 @@
+package helpers
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func at(minutes int) time.Time {
+	return t0.Add(time.Duration(minutes) * time.Minute)
+}
+
+func transition(minutes int, from, to string) Transition {
+	return Transition{Key: "deployment.apps/payments/api", From: from, To: to, Timestamp: at(minutes)}
+}
+
+func TestDowntime(t *testing.T) {
+	tests := []struct {
+		name        string
+		transitions []Transition
+		from, to    time.Time
+		want        []interval
+	}{
+		{"no transitions", nil, at(0), at(60), nil},
+		{"outage inside the window", []Transition{
+			transition(10, StateHealthy, StateUnavailable),
+			transition(20, StateUnavailable, StateHealthy),
+		}, at(0), at(60), []interval{{at(10), at(20)}}},
+		{"still down", []Transition{
+			transition(50, StateHealthy, StateInvalid),
+		}, at(0), at(60), []interval{{at(50), at(60)}}},
+		{"down before the window", []Transition{
+			transition(-30, StateHealthy, StateUnavailable),
+			transition(15, StateUnavailable, StateHealthy),
+		}, at(0), at(60), []interval{{at(0), at(15)}}},
+		{"recovered before the window", []Transition{
+			transition(-30, StateHealthy, StateUnavailable),
+			transition(-10, StateUnavailable, StateHealthy),
+		}, at(0), at(60), nil},
+		{"oldest kept transition is a recovery", []Transition{
+			transition(5, StateUnavailable, StateHealthy),
+			transition(30, StateHealthy, StateUnavailable),
+			transition(40, StateUnavailable, StateHealthy),
+		}, at(0), at(60), []interval{{at(0), at(5)}, {at(30), at(40)}}},
+		{"degraded still serves", []Transition{
+			transition(10, StateHealthy, StateDegraded),
+			transition(20, StateDegraded, StateUnavailable),
+			transition(30, StateUnavailable, StateDegraded),
+			transition(40, StateDegraded, StateHealthy),
+		}, at(0), at(60), []interval{{at(20), at(30)}}},
+		{"unavailable to invalid stays down", []Transition{
+			transition(10, StateHealthy, StateUnavailable),
+			transition(20, StateUnavailable, StateInvalid),
+			transition(30, StateInvalid, StateHealthy),
+		}, at(0), at(60), []interval{{at(10), at(30)}}},
+		{"transitions after the window are ignored", []Transition{
+			transition(10, StateHealthy, StateUnavailable),
+			transition(70, StateUnavailable, StateHealthy),
+		}, at(0), at(60), []interval{{at(10), at(60)}}},
+		{"flapping is not downtime", []Transition{
+			transition(10, StateHealthy, StateUnavailable),
+			transition(20, StateUnavailable, StateFlapping),
+			transition(40, StateFlapping, StateHealthy),
+		}, at(0), at(60), []interval{{at(10), at(20)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := downtime(tt.transitions, tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("downtime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlappingAsDowntime(t *testing.T) {
+	defer func(previous bool) { flappingAsDowntime = previous }(flappingAsDowntime)
+	flappingAsDowntime = true
+	transitions := []Transition{
+		transition(10, StateHealthy, StateFlapping),
+		transition(40, StateFlapping, StateHealthy),
+	}
+	if got, want := downtime(transitions, at(0), at(60)), []interval{{at(10), at(40)}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("downtime = %v, want %v", got, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals []interval
+		want      []interval
+	}{
+		{"empty", nil, nil},
+		{"disjoint", []interval{{at(30), at(40)}, {at(0), at(10)}}, []interval{{at(0), at(10)}, {at(30), at(40)}}},
+		{"overlapping", []interval{{at(0), at(20)}, {at(10), at(30)}}, []interval{{at(0), at(30)}}},
+		{"contained", []interval{{at(0), at(30)}, {at(10), at(20)}}, []interval{{at(0), at(30)}}},
+		{"touching", []interval{{at(0), at(10)}, {at(10), at(20)}}, []interval{{at(0), at(20)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := merge(tt.intervals); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("merge = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowAvailability(t *testing.T) {
+	tests := []struct {
+		name         string
+		down         []interval
+		observed     time.Duration
+		target       float64
+		availability float64
+		budget       *float64
+	}{
+		{"nothing observed", nil, 0, 0, 100, nil},
+		{"always up", nil, time.Hour, 0, 100, nil},
+		{"quarter down", []interval{{at(0), at(15)}}, time.Hour, 0, 75, nil},
+		{"half the budget", []interval{{at(0), at(36)}}, 100 * time.Hour, 99, 99.4, float(40)},
+		{"budget exhausted", []interval{{at(0), at(120)}}, 100 * time.Hour, 99, 98, float(-100)},
+		{"no budget at 100", []interval{{at(0), at(6)}}, time.Hour, 100, 90, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := windowAvailability(tt.down, tt.observed, tt.target, false)
+			if math.Abs(got.Availability-tt.availability) > 1e-9 {
+				t.Fatalf("availability = %v, want %v", got.Availability, tt.availability)
+			}
+			switch {
+			case tt.budget == nil && got.ErrorBudgetRemaining != nil:
+				t.Fatalf("error budget = %v, want none", *got.ErrorBudgetRemaining)
+			case tt.budget != nil && (got.ErrorBudgetRemaining == nil || math.Abs(*got.ErrorBudgetRemaining-*tt.budget) > 1e-9):
+				t.Fatalf("error budget = %v, want %v", got.ErrorBudgetRemaining, *tt.budget)
+			}
+		})
+	}
+}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+// a window longer than the retained history is only observed from the oldest transition kept
+func TestAvailabilityTruncatedHistory(t *testing.T) {
+	defer func(size int) { historySize = size }(historySize)
+	historySize = 2
+	kvs := NewKeyValueStore()
+	now := time.Now()
+	entry := StatusRecord{Kind: "deployment", Namespace: "payments", Name: "api", State: StateHealthy, CheckedAt: now}
+	kvs.Observe("deployment.apps/payments/api", entry)
+	seen := kvs.inventory["deployment.apps/payments/api"]
+	seen.FirstSeen = now.Add(-48 * time.Hour)
+	kvs.inventory["deployment.apps/payments/api"] = seen
+	for i, state := range []string{StateUnavailable, StateHealthy, StateUnavailable, StateHealthy} {
+		record := entry
+		record.State, record.CheckedAt = state, now.Add(time.Duration(i-4)*time.Hour)
+		if err := kvs.SetStatus("deployment.apps/payments/api", record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report := kvs.Availability(StatusFilter{}, []time.Duration{time.Hour, 24 * time.Hour})
+	if len(report.Resources) != 1 {
+		t.Fatalf("%d resources, want 1", len(report.Resources))
+	}
+	windows := report.Resources[0].Windows
+	if windows["1h"].Truncated {
+		t.Fatal("1h window is covered by the history")
+	}
+	day := windows["24h"]
+	if !day.Truncated || day.Observed != "2h0m0s" || math.Abs(day.Availability-50) > 0.01 {
+		t.Fatalf("24h window = %+v, want truncated to the 2h kept with 50%% availability", day)
+	}
+	cluster := report.Clusters[0].Windows["24h"]
+	if !cluster.Truncated || cluster.Observed != "2h0m0s" {
+		t.Fatalf("cluster 24h window = %+v, want truncated to 2h", cluster)
+	}
+}
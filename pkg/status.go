@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"strings"
+	"time"
+
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+)
+
+// states reported against a monitored resource
+const (
+	StateHealthy     = "healthy"
+	StateDegraded    = "degraded"
+	StateUnavailable = "unavailable"
+	StateInvalid     = "invalid"
+	StateFlapping    = "flapping"
+)
+
+// severity ranks the states from healthy to the worst, states of custom resource rules rank between flapping and invalid
+func severity(state string) int {
+	switch state {
+	case StateHealthy:
+		return 0
+	case StateDegraded:
+		return 1
+	case StateFlapping:
+		return 2
+	case StateInvalid:
+		return 4
+	case StateUnavailable:
+		return 5
+	}
+	return 3
+}
+
+// WorseState returns the more severe of two states, ties between custom states resolve alphabetically so that the
+// result does not depend on the order they are compared in
+func WorseState(a, b string) string {
+	if sa, sb := severity(a), severity(b); sa != sb {
+		if sa > sb {
+			return a
+		}
+		return b
+	}
+	if b < a {
+		return b
+	}
+	return a
+}
+
+// StatusRecord is the value stored in the KeyValueStore for every resource that is not healthy
+type StatusRecord struct {
+	Cluster   string    `json:"cluster,omitempty"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	Reason    string    `json:"reason,omitempty"`
+	SLOTarget float64   `json:"sloTarget,omitempty"` // percent, from the k8sclustervitals.io/slo annotation
+	Since     time.Time `json:"since"`               // first time the resource was seen in this state
+	CheckedAt time.Time `json:"checkedAt"`
+
+	Labels   map[string]string `json:"labels,omitempty"`   // labels of the resource, matched by label selector queries
+	Replicas *Replicas         `json:"replicas,omitempty"` // workloads only
+
+	DependsOn  []string `json:"dependsOn,omitempty"`  // kind/namespace/name of the resources it needs, configured or inferred from the pod template
+	ImpactedBy []string `json:"impactedBy,omitempty"` // the dependencies currently unhealthy
+
+	Silenced      bool       `json:"silenced,omitempty"`
+	SilencedUntil *time.Time `json:"silencedUntil,omitempty"` // from the k8sclustervitals.io/silence-until annotation or a silence
+}
+
+// Replicas are the ready and desired pods of a workload
+type Replicas struct {
+	Ready   int32 `json:"ready"`
+	Desired int32 `json:"desired"`
+}
+
+// StatusFilter narrows status records down, empty fields match every record
+type StatusFilter struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+
+	LabelSelector string `json:"labelSelector,omitempty"` // eg: team=payments,tier!=batch
+	State         string `json:"state,omitempty"`
+
+	Authorized func(namespace string) bool `json:"-"` // namespaces the caller of the api may see, nil allows every namespace
+}
+
+// Validate reports a label selector that cannot be parsed
+func (f StatusFilter) Validate() error {
+	_, err := k8slabels.Parse(f.LabelSelector)
+	return err
+}
+
+func (f StatusFilter) Matches(record StatusRecord) bool {
+	if !((f.Cluster == "" || f.Cluster == record.Cluster) &&
+		(f.Kind == "" || strings.EqualFold(f.Kind, record.Kind)) &&
+		(f.Namespace == "" || f.Namespace == record.Namespace) &&
+		(f.Name == "" || f.Name == record.Name) &&
+		(f.State == "" || strings.EqualFold(f.State, record.State)) &&
+		(f.Authorized == nil || f.Authorized(record.Namespace))) {
+		return false
+	}
+	if f.LabelSelector == "" {
+		return true
+	}
+	selector, err := k8slabels.Parse(f.LabelSelector)
+	if err != nil {
+		return false // an invalid selector matches nothing, callers validate it upfront
+	}
+	return selector.Matches(k8slabels.Set(record.Labels))
+}
+
+// MatchesTransition tells whether the filter matches a transition, the state filter matches the state left as well
+// as the state entered so that a subscriber filtering on a state also sees the resources leaving it
+func (f StatusFilter) MatchesTransition(t Transition) bool {
+	record := t.Record()
+	if f.Matches(record) {
+		return true
+	}
+	if f.State == "" {
+		return false
+	}
+	record.State = t.From
+	return f.Matches(record)
+}
@@ -0,0 +1,27 @@
+package helpers
+
+import "testing"
+
+func TestWorseState(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{StateHealthy, StateDegraded, StateDegraded},
+		{StateDegraded, StateFlapping, StateFlapping},
+		{StateFlapping, "suspended", "suspended"},
+		{"suspended", StateInvalid, StateInvalid},
+		{StateInvalid, StateUnavailable, StateUnavailable},
+		{StateUnavailable, StateDegraded, StateUnavailable},
+		{"suspended", "paused", "paused"},
+		{StateDegraded, StateDegraded, StateDegraded},
+	}
+	for _, tt := range tests {
+		// the order of the failing rules does not matter
+		if got := WorseState(tt.a, tt.b); got != tt.want {
+			t.Errorf("WorseState(%s, %s) = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+		if got := WorseState(tt.b, tt.a); got != tt.want {
+			t.Errorf("WorseState(%s, %s) = %s, want %s", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+	"golang.org/x/net/websocket"
+)
+
+// streamMessage is sent as the data of a server-sent event or as a websocket message. a stream starts with a snapshot
+// of the matching unhealthy resources unless it resumed from a last event id, changes follow
+type streamMessage struct {
+	Type       string                          `json:"type"` // snapshot or change
+	ID         uint64                          `json:"id"`
+	Records    map[string]helpers.StatusRecord `json:"records,omitempty"`
+	Transition *helpers.Transition             `json:"transition,omitempty"`
+}
+
+// how often an idle stream sends a keepalive so that proxies do not cut it
+const streamKeepalive = 15 * time.Second
+
+// stream serves /healthcheck/v1/stream as server-sent events, or as a websocket when the client asks for an upgrade.
+// the status query parameters filter the stream, the Last-Event-ID header or the lastEventId parameter resume it
+func stream(c echo.Context) error {
+	filter, err := statusFilter(c)
+	if err != nil {
+		return apiFailOrText(c, http.StatusBadRequest, codeInvalidArgument, helpers.LogMsg("invalid labelSelector: ", err.Error()))
+	}
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("lastEventId")
+	}
+	var resumeFrom uint64
+	if lastEventID != "" {
+		if resumeFrom, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			return apiFailOrText(c, http.StatusBadRequest, codeInvalidArgument, "last event id must be a number")
+		}
+	}
+	if c.IsWebSocket() {
+		// clients without an Origin header are accepted, eg: load-balancer controllers, browsers only from the same
+		// host or an allowed origin
+		websocket.Server{Handshake: func(_ *websocket.Config, req *http.Request) error {
+			return checkOrigin(req)
+		}, Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			ctx, cancel := context.WithCancel(c.Request().Context())
+			defer cancel()
+			go func() {
+				// the hijacked connection does not cancel the request context, notice the client going away by reading
+				var discard string
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+				cancel()
+			}()
+			streamMessages(ctx, filter, resumeFrom, func(message streamMessage) error {
+				return websocket.JSON.Send(ws, message)
+			}, func() error {
+				// browsers answer the ping with a pong, the receiving goroutine discards it
+				w, err := ws.NewFrameWriter(websocket.PingFrame)
+				if err != nil {
+					return err
+				}
+				if _, err := w.Write(nil); err != nil {
+					return err
+				}
+				return w.Close()
+			})
+		}}.ServeHTTP(c.Response(), c.Request())
+		return nil
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, "text/event-stream")
+	header.Set(echo.HeaderCacheControl, "no-cache")
+	header.Set(echo.HeaderConnection, "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // nginx ingress buffers responses otherwise
+	c.Response().WriteHeader(http.StatusOK)
+	c.Response().Flush()
+	streamMessages(c.Request().Context(), filter, resumeFrom, func(message streamMessage) error {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(c.Response(), "id: %d\nevent: %s\ndata: %s\n\n", message.ID, message.Type, data); err != nil {
+			return err
+		}
+		c.Response().Flush()
+		return nil
+	}, func() error {
+		if _, err := fmt.Fprint(c.Response(), ": keepalive\n\n"); err != nil {
+			return err
+		}
+		c.Response().Flush()
+		return nil
+	})
+	return nil
+}
+
+// origins besides the serving host allowed to open a websocket stream, comma separated, eg: https://ops.example.com
+var streamAllowedOrigins = strings.Split(os.Getenv("STREAM_ALLOWED_ORIGINS"), ",")
+
+// checkOrigin rejects websocket upgrades of browsers on another site, the stream would otherwise be readable
+// cross-site with the cookies or client certificate of the user
+func checkOrigin(req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(u.Host, req.Host) {
+		return nil
+	}
+	for _, allowed := range streamAllowedOrigins {
+		if allowed = strings.TrimSpace(allowed); allowed != "" && strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
+
+// streamMessages sends the snapshot, or the missed events, then the changes until the client goes away
+func streamMessages(ctx context.Context, filter helpers.StatusFilter, resumeFrom uint64, send func(streamMessage) error, keepalive func() error) {
+	events, last, resumed, cancel := cacheStore.Subscribe(resumeFrom)
+	defer cancel()
+	if !resumed {
+		if send(streamMessage{Type: "snapshot", ID: last, Records: cacheStore.GetAllStatus(filter)}) != nil {
+			return
+		}
+	}
+	ticker := time.NewTicker(streamKeepalive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// fell behind, the client reconnects with its last event id
+				return
+			}
+			if !filter.MatchesTransition(event.Transition) {
+				continue
+			}
+			transition := event.Transition
+			if send(streamMessage{Type: "change", ID: event.ID, Transition: &transition}) != nil {
+				return
+			}
+		case <-ticker.C:
+			if keepalive != nil && keepalive() != nil {
+				return
+			}
+		}
+	}
+}
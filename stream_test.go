@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// a stream filtered on a state reports the resources entering it and the ones leaving it
+func TestStreamStateFilterMatchesRecoveries(t *testing.T) {
+	cacheStore = helpers.NewKeyValueStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := make(chan streamMessage, 10)
+	go streamMessages(ctx, helpers.StatusFilter{State: helpers.StateUnavailable}, 0, func(message streamMessage) error {
+		messages <- message
+		return nil
+	}, nil)
+	if message := <-messages; message.Type != "snapshot" {
+		t.Fatalf("first message %s, want the snapshot", message.Type)
+	}
+
+	key := "deployment.apps/payments/api"
+	record := helpers.StatusRecord{Kind: "deployment", Namespace: "payments", Name: "api", State: helpers.StateDegraded, CheckedAt: time.Now()}
+	for _, state := range []string{helpers.StateDegraded, helpers.StateUnavailable} {
+		record.State = state
+		if err := cacheStore.SetStatus(key, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cacheStore.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][2]string{{helpers.StateDegraded, helpers.StateUnavailable}, {helpers.StateUnavailable, helpers.StateHealthy}}
+	for _, w := range want {
+		select {
+		case message := <-messages:
+			if message.Transition.From != w[0] || message.Transition.To != w[1] {
+				t.Fatalf("change %s -> %s, want %s -> %s", message.Transition.From, message.Transition.To, w[0], w[1])
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no change %s -> %s streamed", w[0], w[1])
+		}
+	}
+	select {
+	case message := <-messages:
+		t.Fatalf("unexpected change %s -> %s", message.Transition.From, message.Transition.To)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	previous := streamAllowedOrigins
+	streamAllowedOrigins = []string{"https://ops.example.com"}
+	t.Cleanup(func() { streamAllowedOrigins = previous })
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"", true},
+		{"http://vitals.example.com", true},
+		{"https://ops.example.com", true},
+		{"https://evil.example.com", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://vitals.example.com/healthcheck/v1/stream", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if err := checkOrigin(req); (err == nil) != tt.allowed {
+			t.Errorf("origin %q: error %v, want allowed %v", tt.origin, err, tt.allowed)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// https is configured from the environment, the http and grpc servers stay plain unless a certificate is given:
+//
+//	TLS_CERT_FILE, TLS_KEY_FILE   serving certificate and key, reloaded when they change, eg: cert-manager rotations
+//	TLS_CLIENT_CA_FILE            optional, client certificates are verified against it and used by AUTH_MTLS
+//	TLS_CLIENT_AUTH               verify-if-given (default) or require, only with a client CA
+//	TLS_MIN_VERSION               1.2 (default) or 1.3
+//	TLS_CIPHER_SUITES             comma separated names of the TLS 1.2 suites, eg: TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+type tlsSettings struct {
+	certFile, keyFile, clientCAFile string
+	clientAuth                      tls.ClientAuthType
+	minVersion                      uint16
+	cipherSuites                    []uint16
+}
+
+func tlsEnabled() bool {
+	return os.Getenv("TLS_CERT_FILE") != "" || os.Getenv("TLS_KEY_FILE") != ""
+}
+
+func loadTLSSettings() (tlsSettings, error) {
+	settings := tlsSettings{
+		certFile:     os.Getenv("TLS_CERT_FILE"),
+		keyFile:      os.Getenv("TLS_KEY_FILE"),
+		clientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		minVersion:   tls.VersionTLS12,
+	}
+	if settings.certFile == "" || settings.keyFile == "" {
+		return settings, errors.New("TLS_CERT_FILE and TLS_KEY_FILE are both required")
+	}
+	switch os.Getenv("TLS_MIN_VERSION") {
+	case "", "1.2":
+	case "1.3":
+		settings.minVersion = tls.VersionTLS13
+	default:
+		return settings, errors.New(helpers.LogMsg("unsupported TLS_MIN_VERSION ", os.Getenv("TLS_MIN_VERSION"), ", expected 1.2 or 1.3"))
+	}
+	if settings.clientCAFile != "" {
+		switch os.Getenv("TLS_CLIENT_AUTH") {
+		case "", "verify-if-given":
+			// probes and bearer token callers do not present a certificate
+			settings.clientAuth = tls.VerifyClientCertIfGiven
+		case "require":
+			settings.clientAuth = tls.RequireAndVerifyClientCert
+		default:
+			return settings, errors.New(helpers.LogMsg("unsupported TLS_CLIENT_AUTH ", os.Getenv("TLS_CLIENT_AUTH"), ", expected verify-if-given or require"))
+		}
+	}
+	if names := os.Getenv("TLS_CIPHER_SUITES"); names != "" {
+		suites := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			suites[suite.Name] = suite.ID
+		}
+		for _, name := range strings.Split(names, ",") {
+			id, ok := suites[strings.TrimSpace(name)]
+			if !ok {
+				return settings, errors.New(helpers.LogMsg("unknown or insecure cipher suite ", name))
+			}
+			settings.cipherSuites = append(settings.cipherSuites, id)
+		}
+	}
+	return settings, nil
+}
+
+// certReloader serves the latest certificate and client CA, the files are read again every 15s and swapped when
+// their content changed. a broken rotation keeps the previous certificate
+type certReloader struct {
+	settings tlsSettings
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	loaded   [][]byte // content of the cert, key and client CA files last loaded
+}
+
+func newCertReloader(settings tlsSettings) (*certReloader, error) {
+	r := &certReloader{settings: settings}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload reads the files and swaps the certificate when they changed, reports whether they did
+func (r *certReloader) reload() (bool, error) {
+	files := []string{r.settings.certFile, r.settings.keyFile}
+	if r.settings.clientCAFile != "" {
+		files = append(files, r.settings.clientCAFile)
+	}
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return false, err
+		}
+		contents[i] = data
+	}
+	r.mu.RLock()
+	changed := len(r.loaded) != len(contents)
+	for i := 0; !changed && i < len(contents); i++ {
+		changed = !bytes.Equal(r.loaded[i], contents[i])
+	}
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	cert, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		return false, err
+	}
+	var clientCA *x509.CertPool
+	if len(contents) > 2 {
+		clientCA = x509.NewCertPool()
+		if !clientCA.AppendCertsFromPEM(contents[2]) {
+			return false, errors.New(helpers.LogMsg("no certificate found in ", r.settings.clientCAFile))
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.clientCA, r.loaded = &cert, clientCA, contents
+	return true, nil
+}
+
+// watch reloads the files until ctx is cancelled
+func (r *certReloader) watch(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			time.Sleep(15 * time.Second)
+			changed, err := r.reload()
+			if err != nil {
+				// cert-manager writes the files one by one, the next pass picks up a consistent pair
+				log.Error().Str("caller", "tls.go").Msg(helpers.LogMsg("failed to reload the tls certificate, keeping the previous one: ", err.Error()))
+			} else if changed {
+				log.Info().Str("caller", "tls.go").Msg("tls certificate reloaded")
+			}
+		}
+	}
+}
+
+// config returns the tls configuration of a server, every handshake gets the latest certificate and client CA
+func (r *certReloader) config(nextProtos ...string) *tls.Config {
+	base := &tls.Config{
+		MinVersion:   r.settings.minVersion,
+		CipherSuites: r.settings.cipherSuites,
+		NextProtos:   nextProtos,
+	}
+	config := base.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		current := base.Clone()
+		current.Certificates = []tls.Certificate{*r.cert}
+		if r.clientCA != nil {
+			current.ClientCAs = r.clientCA
+			current.ClientAuth = r.settings.clientAuth
+		}
+		return current, nil
+	}
+	return config
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	k8client "github.com/vivekganesan01/k8sClusterVitals/internals"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// the v2 api answers with typed json, or text/plain when the client prefers it, and reports every error as an apiError.
+// apiRoutes is the single source of the handlers and of the /openapi.json document
+
+const apiV2 = "/healthcheck/v2"
+
+// apiRoute documents and serves an endpoint of the v2 api
+type apiRoute struct {
+	Method      string
+	Path        string // echo path, eg: /healthcheck/v2/groups/:name/health
+	OperationID string
+	Summary     string
+	Params      []apiParam
+	Body        interface{} // request body, nil when there is none
+	Status      int         // status of a successful response
+	Response    interface{} // body of a successful response, nil when there is none
+	Unhealthy   bool        // the same body is sent with 503 when not healthy
+	EventStream bool        // served as server-sent events
+	Handler     echo.HandlerFunc
+}
+
+type apiParam struct {
+	Name        string
+	In          string // query or path
+	Description string
+}
+
+// filterParams are the query parameters of statusFilter
+var filterParams = []apiParam{
+	{Name: "cluster", In: "query", Description: "cluster name in multi-cluster mode"},
+	{Name: "kind", In: "query", Description: "eg: deployment"},
+	{Name: "namespace", In: "query"},
+	{Name: "name", In: "query"},
+	{Name: "labelSelector", In: "query", Description: "eg: team=payments,tier!=batch"},
+	{Name: "state", In: "query", Description: "eg: degraded"},
+}
+
+func withParams(params ...apiParam) []apiParam {
+	return append(params, filterParams...)
+}
+
+var namespaceParam = apiParam{Name: "namespace", In: "path"}
+
+// apiError is the body of every v2 error response
+type apiError struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"` // eg: invalid_argument, not_found
+	Message string `json:"message"`
+}
+
+func (e apiError) Text() string {
+	return e.Error.Message
+}
+
+// error codes of apiErrorDetail
+const (
+	codeInvalidArgument = "invalid_argument"
+	codeNotFound        = "not_found"
+	codeNotAcceptable   = "not_acceptable"
+	codeUnavailable     = "unavailable"
+	codeInternal        = "internal"
+)
+
+func apiFail(c echo.Context, status int, code string, message string) error {
+	return respond(c, status, apiError{Error: apiErrorDetail{Status: status, Code: code, Message: message}})
+}
+
+// apiFailOrText answers with the error envelope on the v2 api and with plain text on v1, for the handlers and
+// middlewares serving both
+func apiFailOrText(c echo.Context, status int, code string, message string) error {
+	if strings.HasPrefix(c.Request().URL.Path, apiV2) {
+		return apiFail(c, status, code, message)
+	}
+	return c.String(status, message)
+}
+
+// texter is implemented by the responses which have a text/plain representation
+type texter interface {
+	Text() string
+}
+
+// respond negotiates the representation of the body with the Accept header, json unless text/plain is preferred
+func respond(c echo.Context, status int, body interface{}) error {
+	if body == nil {
+		return c.NoContent(status)
+	}
+	switch negotiate(c.Request().Header.Get(echo.HeaderAccept)) {
+	case echo.MIMETextPlain:
+		if t, ok := body.(texter); ok {
+			return c.String(status, t.Text())
+		}
+		if _, ok := body.(apiError); !ok {
+			return c.JSON(http.StatusNotAcceptable, apiError{Error: apiErrorDetail{Status: http.StatusNotAcceptable, Code: codeNotAcceptable, Message: "this resource is only available as application/json"}})
+		}
+	case "":
+		if _, ok := body.(apiError); !ok {
+			return c.JSON(http.StatusNotAcceptable, apiError{Error: apiErrorDetail{Status: http.StatusNotAcceptable, Code: codeNotAcceptable, Message: "supported media types are application/json and text/plain"}})
+		}
+	}
+	return c.JSON(status, body)
+}
+
+// negotiate picks application/json or text/plain from an Accept header, json wins ties and an empty header,
+// empty when neither is acceptable
+func negotiate(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return echo.MIMEApplicationJSON
+	}
+	jsonQ, textQ := -1.0, -1.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		parts := strings.Split(mediaRange, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(parts[0]))
+		q := 1.0
+		for _, param := range parts[1:] {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				if parsed, err := strconv.ParseFloat(strings.TrimPrefix(v, "q="), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		switch mediaType {
+		case "*/*":
+			jsonQ, textQ = maxQ(jsonQ, q), maxQ(textQ, q-0.0001) // json first on wildcards
+		case "application/*", echo.MIMEApplicationJSON:
+			jsonQ = maxQ(jsonQ, q)
+		case "text/*", echo.MIMETextPlain:
+			textQ = maxQ(textQ, q)
+		}
+	}
+	switch {
+	case jsonQ <= 0 && textQ <= 0:
+		return ""
+	case textQ > jsonQ:
+		return echo.MIMETextPlain
+	}
+	return echo.MIMEApplicationJSON
+}
+
+func maxQ(a, b float64) float64 {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+// apiErrorHandler reports the errors of the v2 api, eg: unknown routes, as apiError and leaves the others to echo
+func apiErrorHandler(e *echo.Echo) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed || !strings.HasPrefix(c.Request().URL.Path, apiV2) {
+			e.DefaultHTTPErrorHandler(err, c)
+			return
+		}
+		status, code, message := http.StatusInternalServerError, codeInternal, err.Error()
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			status, message = httpErr.Code, http.StatusText(httpErr.Code)
+			switch status {
+			case http.StatusNotFound:
+				code = codeNotFound
+			case http.StatusMethodNotAllowed:
+				code = "method_not_allowed"
+			case http.StatusBadRequest:
+				code = codeInvalidArgument
+			}
+		}
+		if err := apiFail(c, status, code, message); err != nil {
+			log.Error().Str("caller", "v2.go").Msg(helpers.LogMsg("failed to send error response: ", err.Error()))
+		}
+	}
+}
+
+type healthResponse struct {
+	Status    string `json:"status"`    // ok, not_ok or warming_up
+	Unhealthy int    `json:"unhealthy"` // unsilenced unhealthy resources
+	Silenced  int    `json:"silenced"`  // unhealthy resources left out of the verdict
+}
+
+func (r healthResponse) Text() string {
+	return r.Status
+}
+
+// statusItem is a status record with its key
+type statusItem struct {
+	Key string `json:"key"`
+	helpers.StatusRecord
+}
+
+type statusResponse struct {
+	Items []statusItem `json:"items"`
+}
+
+func (r statusResponse) Text() string {
+	var lines []string
+	for _, item := range r.Items {
+		lines = append(lines, helpers.LogMsg(item.Key, " ", item.State, " ", item.Reason))
+	}
+	return textLines(lines)
+}
+
+type inventoryResponse struct {
+	Items []helpers.InventoryEntry `json:"items"`
+}
+
+func (r inventoryResponse) Text() string {
+	var lines []string
+	for _, entry := range r.Items {
+		lines = append(lines, entry.Key)
+	}
+	return textLines(lines)
+}
+
+type historyResponse struct {
+	Items []helpers.Transition `json:"items"`
+}
+
+func (r historyResponse) Text() string {
+	var lines []string
+	for _, t := range r.Items {
+		lines = append(lines, helpers.LogMsg(t.Timestamp.Format(time.RFC3339), " ", t.Key, " ", t.From, " -> ", t.To, " ", t.Reason))
+	}
+	return textLines(lines)
+}
+
+type triageResponse struct {
+	Items []helpers.TriageFinding `json:"items"`
+}
+
+func (r triageResponse) Text() string {
+	var lines []string
+	for _, finding := range r.Items {
+		lines = append(lines, helpers.LogMsg(finding.Key, " ", finding.RootCause.State, " impacts ", strconv.Itoa(len(finding.Impacted))))
+	}
+	return textLines(lines)
+}
+
+type groupsResponse struct {
+	Items []helpers.GroupStatus `json:"items"`
+}
+
+type groupHealthResponse struct {
+	helpers.GroupStatus
+}
+
+func (r groupHealthResponse) Text() string {
+	return r.Status
+}
+
+func (f fleet) Text() string {
+	return f.Status
+}
+
+type silencesResponse struct {
+	Items []helpers.Silence `json:"items"`
+}
+
+type scrapeConfigurationResponse struct {
+	Items []clusterScrapeConfiguration `json:"items"`
+}
+
+type clusterScrapeConfiguration struct {
+	Cluster string `json:"cluster,omitempty"`
+	helpers.ScrapeConfiguration
+}
+
+func textLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// apiRoutes lists the endpoints of the v2 api
+func apiRoutes() []apiRoute {
+	health := func(c echo.Context) error {
+		filter, err := statusFilter(c)
+		if err != nil {
+			return apiFail(c, http.StatusBadRequest, codeInvalidArgument, helpers.LogMsg("invalid labelSelector: ", err.Error()))
+		}
+		records := cacheStore.GetAllStatus(filter)
+		response := healthResponse{Status: "ok", Unhealthy: helpers.Unsilenced(records)}
+		response.Silenced = len(records) - response.Unhealthy
+		switch {
+		case k8client.WarmingUp():
+			response.Status = "warming_up"
+			return respond(c, http.StatusServiceUnavailable, response)
+		case response.Unhealthy > 0:
+			response.Status = "not_ok"
+			return respond(c, http.StatusServiceUnavailable, response)
+		}
+		return respond(c, http.StatusOK, response)
+	}
+	status := func(c echo.Context) error {
+		filter, err := statusFilter(c)
+		if err != nil {
+			return apiFail(c, http.StatusBadRequest, codeInvalidArgument, helpers.LogMsg("invalid labelSelector: ", err.Error()))
+		}
+		response := statusResponse{Items: []statusItem{}}
+		for key, record := range cacheStore.GetAllStatus(filter) {
+			response.Items = append(response.Items, statusItem{Key: key, StatusRecord: record})
+		}
+		sort.Slice(response.Items, func(i, j int) bool { return response.Items[i].Key < response.Items[j].Key })
+		return respond(c, http.StatusOK, response)
+	}
+	return []apiRoute{
+		{Method: http.MethodGet, Path: apiV2 + "/health", OperationID: "getHealth", Summary: "Health verdict of the resources matching the filter",
+			Params: filterParams, Status: http.StatusOK, Response: healthResponse{}, Unhealthy: true, Handler: health},
+		{Method: http.MethodGet, Path: apiV2 + "/namespaces/:namespace/health", OperationID: "getNamespaceHealth", Summary: "Health verdict of a namespace",
+			Params: withParams(namespaceParam), Status: http.StatusOK, Response: healthResponse{}, Unhealthy: true, Handler: health},
+		{Method: http.MethodGet, Path: apiV2 + "/status", OperationID: "listStatus", Summary: "Unhealthy resources matching the filter",
+			Params: filterParams, Status: http.StatusOK, Response: statusResponse{}, Handler: status},
+		{Method: http.MethodGet, Path: apiV2 + "/namespaces/:namespace/status", OperationID: "listNamespaceStatus", Summary: "Unhealthy resources of a namespace",
+			Params: withParams(namespaceParam), Status: http.StatusOK, Response: statusResponse{}, Handler: status},
+		{Method: http.MethodGet, Path: apiV2 + "/stream", OperationID: "streamStatus", Summary: "Snapshot of the unhealthy resources followed by their state changes, as server-sent events or a websocket",
+			Params: withParams(apiParam{Name: "lastEventId", In: "query", Description: "resumes after the event, also read from the Last-Event-ID header"}), Status: http.StatusOK, Response: streamMessage{}, EventStream: true, Handler: stream},
+		{Method: http.MethodGet, Path: apiV2 + "/inventory", OperationID: "listInventory", Summary: "Every monitored resource, healthy ones included",
+			Params: filterParams, Status: http.StatusOK, Response: inventoryResponse{}, Handler: func(c echo.Context) error {
+				filter, err := statusFilter(c)
+				if err != nil {
+					return apiFail(c, http.StatusBadRequest, codeInvalidArgument, helpers.LogMsg("invalid labelSelector: ", err.Error()))
+				}
+				return respond(c, http.StatusOK, inventoryResponse{Items: append([]helpers.InventoryEntry{}, cacheStore.Inventory(filter)...)})
+			}},
+		{Method: http.MethodGet, Path: apiV2 + "/history", OperationID: "listHistory", Summary: "State transitions of the resources matching the filter",
+			Params: withParams(apiParam{Name: "since", In: "query", Description: "RFC3339 timestamp or a duration such as 1h"}), Status: http.StatusOK, Response: historyResponse{}, Handler: func(c echo.Context) error {
+				filter, err := statusFilter(c)
+				if err != nil {
+					return apiFail(c, http.StatusBadRequest, codeInvalidArgument, helpers.LogMsg("invalid labelSelector: ", err.Error()))
+				}
+				since, err := parseSince(c.QueryParam("since"))
+				if err != nil {
+					return apiFail(c, http.StatusBadRequest, codeInvalidArgument, "since must be a RFC3339 timestamp or a duration such as 1h")
+				}
+				return respond(c, http.StatusOK, historyResponse{Items: append([]helpers.Transition{}, cacheStore.History(filter, since)...)})
+			}},
+		{Method: http.MethodGet, Path: apiV2 + "/triage", OperationID: "triage", Summary: "Root causes of the unhealthy resources matching the filter",
+			Params: filterParams, Status: http.StatusOK, Response: triageResponse{}, Handler: func(c echo.Context) error {
+				filter, err := statusFilter(c)
+				if err != nil {
+					return apiFail(c, http.StatusBadRequest, codeInvalidArgument, helpers.LogMsg("invalid labelSelector: ", err.Error()))
+				}
+				return respond(c, http.StatusOK, triageResponse{Items: cacheStore.Triage(filter)})
+			}},
+		{Method: http.MethodGet, Path: apiV2 + "/clusters", OperationID: "getClusters", Summary: "Readiness and unhealthy resources of every monitored cluster",
+			Status: http.StatusOK, Response: fleet{}, Unhealthy: true, Handler: func(c echo.Context) error {
+				fleet := fleetStatus()
+				if fleet.Status != "ok" {
+					return respond(c, http.StatusServiceUnavailable, fleet)
+				}
+				return respond(c, http.StatusOK, fleet)
+			}},
+		{Method: http.MethodGet, Path: apiV2 + "/groups", OperationID: "listGroups", Summary: "Verdict of every health group",
+			Params: []apiParam{filterParams[0]}, Status: http.StatusOK, Response: groupsResponse{}, Handler: func(c echo.Context) error {
+				response := groupsResponse{Items: []helpers.GroupStatus{}}
+				for _, group := range k8client.HealthGroups(c.QueryParam("cluster")) {
+					response.Items = append(response.Items, cacheStore.GroupHealth(group))
+				}
+				return respond(c, http.StatusOK, response)
+			}},
+		{Method: http.MethodGet, Path: apiV2 + "/groups/:name/health", OperationID: "getGroupHealth", Summary: "Verdict of a health group",
+			Params: []apiParam{{Name: "name", In: "path"}, filterParams[0]}, Status: http.StatusOK, Response: groupHealthResponse{}, Unhealthy: true, Handler: func(c echo.Context) error {
+				for _, group := range k8client.HealthGroups(c.QueryParam("cluster")) {
+					if group.Name != c.Param("name") {
+						continue
+					}
+					if k8client.WarmingUp() {
+						// no verdict yet, same as /health
+						return respond(c, http.StatusServiceUnavailable, groupHealthResponse{helpers.GroupStatus{Name: group.Name, Cluster: group.Cluster, Policy: group.Policy, Status: "warming_up", Members: []helpers.GroupMemberStatus{}}})
+					}
+					response := groupHealthResponse{cacheStore.GroupHealth(group)}
+					if anonymous(c) {
+						response.GroupStatus = groupVerdict(response.GroupStatus)
+					}
+					if response.Status != "ok" {
+						return respond(c, http.StatusServiceUnavailable, response)
+					}
+					return respond(c, http.StatusOK, response)
+				}
+				return apiFail(c, http.StatusNotFound, codeNotFound, helpers.LogMsg("health group ", c.Param("name"), " not found"))
+			}},
+		{Method: http.MethodGet, Path: apiV2 + "/slo", OperationID: "getAvailability", Summary: "Rolling availability and error budgets",
+			Params: filterParams[:4], Status: http.StatusOK, Response: helpers.AvailabilityReport{}, Handler: func(c echo.Context) error {
+				filter := helpers.StatusFilter{Cluster: c.QueryParam("cluster"), Kind: c.QueryParam("kind"), Namespace: c.QueryParam("namespace"), Name: c.QueryParam("name"), Authorized: namespaceAuthorizer(c)}
+				return respond(c, http.StatusOK, cacheStore.Availability(filter, helpers.SLOWindows()))
+			}},
+		{Method: http.MethodGet, Path: apiV2 + "/silences", OperationID: "listSilences", Summary: "Active silences",
+			Status: http.StatusOK, Response: silencesResponse{}, Handler: func(c echo.Context) error {
+				return respond(c, http.StatusOK, silencesResponse{Items: append([]helpers.Silence{}, cacheStore.Silences()...)})
+			}},
+		{Method: http.MethodPost, Path: apiV2 + "/silences", OperationID: "createSilence", Summary: "Silences the resources matching the matchers for a duration",
+			Body: silenceRequest{}, Status: http.StatusCreated, Response: helpers.Silence{}, Handler: func(c echo.Context) error {
+				if k8client.Role() == k8client.RoleFollower {
+					return apiFail(c, http.StatusServiceUnavailable, codeUnavailable, "silences must be created on the leader")
+				}
+				var req silenceRequest
+				if err := c.Bind(&req); err != nil {
+					return apiFail(c, http.StatusBadRequest, codeInvalidArgument, "invalid silence")
+				}
+				duration, err := time.ParseDuration(req.Duration)
+				if err != nil {
+					return apiFail(c, http.StatusBadRequest, codeInvalidArgument, "duration must be a duration such as 2h")
+				}
+				silence, err := cacheStore.AddSilence(helpers.Silence{Matchers: req.Matchers, Author: req.Author, Comment: req.Comment}, duration)
+				if err != nil {
+					return apiFail(c, http.StatusBadRequest, codeInvalidArgument, err.Error())
+				}
+				log.Info().Str("caller", "v2.go").Msg(helpers.LogMsg("silence ", silence.ID, " created by ", silence.Author, " until ", silence.EndsAt.Format(time.RFC3339)))
+				return respond(c, http.StatusCreated, silence)
+			}},
+		{Method: http.MethodDelete, Path: apiV2 + "/silences/:id", OperationID: "deleteSilence", Summary: "Expires a silence",
+			Params: []apiParam{{Name: "id", In: "path"}}, Status: http.StatusNoContent, Handler: func(c echo.Context) error {
+				if k8client.Role() == k8client.RoleFollower {
+					return apiFail(c, http.StatusServiceUnavailable, codeUnavailable, "silences must be deleted on the leader")
+				}
+				if err := cacheStore.DeleteSilence(c.Param("id")); err != nil {
+					return apiFail(c, http.StatusNotFound, codeNotFound, err.Error())
+				}
+				return c.NoContent(http.StatusNoContent)
+			}},
+		{Method: http.MethodGet, Path: apiV2 + "/scrape_configuration", OperationID: "getScrapeConfiguration", Summary: "Active scrape configuration of every monitored cluster",
+			Status: http.StatusOK, Response: scrapeConfigurationResponse{}, Handler: func(c echo.Context) error {
+				response := scrapeConfigurationResponse{Items: []clusterScrapeConfiguration{}}
+				for cluster, config := range k8client.ScrapeConfigurations() {
+					response.Items = append(response.Items, clusterScrapeConfiguration{Cluster: cluster, ScrapeConfiguration: config})
+				}
+				sort.Slice(response.Items, func(i, j int) bool { return response.Items[i].Cluster < response.Items[j].Cluster })
+				return respond(c, http.StatusOK, response)
+			}},
+	}
+}
+
+// registerAPIv2 serves the v2 api and its openapi document
+func registerAPIv2(e *echo.Echo) {
+	routes := apiRoutes()
+	for _, route := range routes {
+		e.Add(route.Method, route.Path, route.Handler)
+	}
+	spec := openAPISpec(routes)
+	e.GET("/openapi.json", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, spec)
+	})
+	e.HTTPErrorHandler = apiErrorHandler(e)
+	if contractCheckEnabled() {
+		e.Use(contractValidator(routes, spec))
+	}
+	if err := checkContract(e, routes, spec); err != nil {
+		log.Error().Str("caller", "v2.go").Msg(helpers.LogMsg("the openapi document is out of sync with the handlers: ", err.Error()))
+	}
+}
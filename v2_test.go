@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	helpers "github.com/vivekganesan01/k8sClusterVitals/pkg"
+)
+
+// seedStore fills the store with an unhealthy deployment, a healthy secret, a transition and a silence
+func seedStore(t *testing.T) string {
+	t.Helper()
+	cacheStore = helpers.NewKeyValueStore()
+	now := time.Now()
+	api := helpers.StatusRecord{Kind: "deployment", Namespace: "payments", Name: "api", State: helpers.StateDegraded, Reason: "3/6 available", CheckedAt: now,
+		Labels: map[string]string{"team": "payments"}, Replicas: &helpers.Replicas{Ready: 3, Desired: 6}, DependsOn: []string{"secret/payments/tls"}}
+	cacheStore.Observe("deployment.apps/payments/api", api)
+	if err := cacheStore.SetStatus("deployment.apps/payments/api", api); err != nil {
+		t.Fatal(err)
+	}
+	cacheStore.Observe("secrets.payments/tls", helpers.StatusRecord{Kind: "secret", Namespace: "payments", Name: "tls", State: helpers.StateHealthy, CheckedAt: now})
+	silence, err := cacheStore.AddSilence(helpers.Silence{Matchers: helpers.StatusFilter{Namespace: "batch"}, Author: "ops"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return silence.ID
+}
+
+func newTestAPI() (*echo.Echo, []apiRoute, openAPI) {
+	e := echo.New()
+	registerAPIv2(e)
+	routes := apiRoutes()
+	return e, routes, openAPISpec(routes)
+}
+
+func serve(e *echo.Echo, method string, target string, accept string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if accept != "" {
+		req.Header.Set(echo.HeaderAccept, accept)
+	}
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIv2ServesDocument(t *testing.T) {
+	e, routes, spec := newTestAPI()
+	if err := checkContract(e, routes, spec); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// every route but the event stream is called and its response validated against the document
+func TestAPIv2Contract(t *testing.T) {
+	silenceID := seedStore(t)
+	e, routes, spec := newTestAPI()
+	tests := []struct {
+		method, route, target, body string
+		status                      int
+	}{
+		{http.MethodGet, "/health", "/health", "", http.StatusServiceUnavailable},
+		{http.MethodGet, "/health", "/health?namespace=batch", "", http.StatusOK},
+		{http.MethodGet, "/health", "/health?labelSelector=team%3D%3D%3D", "", http.StatusBadRequest},
+		{http.MethodGet, "/namespaces/:namespace/health", "/namespaces/payments/health", "", http.StatusServiceUnavailable},
+		{http.MethodGet, "/status", "/status?kind=deployment", "", http.StatusOK},
+		{http.MethodGet, "/namespaces/:namespace/status", "/namespaces/payments/status", "", http.StatusOK},
+		{http.MethodGet, "/inventory", "/inventory", "", http.StatusOK},
+		{http.MethodGet, "/history", "/history?since=1h", "", http.StatusOK},
+		{http.MethodGet, "/history", "/history?since=yesterday", "", http.StatusBadRequest},
+		{http.MethodGet, "/triage", "/triage", "", http.StatusOK},
+		{http.MethodGet, "/clusters", "/clusters", "", http.StatusServiceUnavailable}, // no cluster is ready
+		{http.MethodGet, "/groups", "/groups", "", http.StatusOK},
+		{http.MethodGet, "/groups/:name/health", "/groups/checkout/health", "", http.StatusNotFound},
+		{http.MethodGet, "/slo", "/slo?namespace=payments", "", http.StatusOK},
+		{http.MethodGet, "/silences", "/silences", "", http.StatusOK},
+		{http.MethodPost, "/silences", "/silences", `{"matchers":{"namespace":"payments"},"duration":"2h","author":"ops"}`, http.StatusCreated},
+		{http.MethodPost, "/silences", "/silences", `{"matchers":{"namespace":"payments"},"duration":"later"}`, http.StatusBadRequest},
+		{http.MethodPost, "/silences", "/silences", `{"matchers":{},"duration":"2h"}`, http.StatusBadRequest},
+		{http.MethodDelete, "/silences/:id", "/silences/" + silenceID, "", http.StatusNoContent},
+		{http.MethodDelete, "/silences/:id", "/silences/unknown", "", http.StatusNotFound},
+		{http.MethodGet, "/scrape_configuration", "/scrape_configuration", "", http.StatusOK},
+		// the stream itself is not buffered, its bad requests get the envelope
+		{http.MethodGet, "/stream", "/stream?labelSelector=team%3D%3D%3D", "", http.StatusBadRequest},
+		{http.MethodGet, "/stream", "/stream?lastEventId=latest", "", http.StatusBadRequest},
+	}
+	called := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			rec := serve(e, tt.method, apiV2+tt.target, "", tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, expected %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if err := validateResponse(spec, tt.method, apiV2+tt.route, rec.Code, rec.Header().Get(echo.HeaderContentType), rec.Body.Bytes()); err != nil {
+				t.Fatalf("contract violation: %s, body: %s", err, rec.Body.String())
+			}
+		})
+		called[tt.method+" "+apiV2+tt.route] = true
+	}
+	for _, route := range routes {
+		if !route.EventStream && !called[route.Method+" "+route.Path] {
+			t.Errorf("%s %s is not covered", route.Method, route.Path)
+		}
+	}
+}
+
+func TestAPIv2Negotiation(t *testing.T) {
+	seedStore(t)
+	e, _, spec := newTestAPI()
+	tests := []struct {
+		name, route, target, accept string
+		status                      int
+		contentType                 string
+		body                        string
+	}{
+		{"text health", "/health", "/health", "text/plain", http.StatusServiceUnavailable, echo.MIMETextPlain, "not_ok"},
+		{"text preferred", "/health", "/health?namespace=batch", "application/json;q=0.5, text/plain", http.StatusOK, echo.MIMETextPlain, "ok"},
+		{"json preferred", "/health", "/health?namespace=batch", "text/plain;q=0.5, application/json", http.StatusOK, echo.MIMEApplicationJSON, `"status":"ok"`},
+		{"wildcard", "/health", "/health?namespace=batch", "*/*", http.StatusOK, echo.MIMEApplicationJSON, `"status":"ok"`},
+		{"text status", "/status", "/status", "text/plain", http.StatusOK, echo.MIMETextPlain, "deployment.apps/payments/api degraded 3/6 available"},
+		{"json only resource", "/silences", "/silences", "text/plain", http.StatusNotAcceptable, echo.MIMEApplicationJSON, `"code":"not_acceptable"`},
+		{"unsupported", "/status", "/status", "image/png", http.StatusNotAcceptable, echo.MIMEApplicationJSON, `"code":"not_acceptable"`},
+		{"text error", "/health", "/health?labelSelector=team%3D%3D%3D", "text/plain", http.StatusBadRequest, echo.MIMETextPlain, "invalid labelSelector"},
+		{"json error", "/history", "/history?since=yesterday", "", http.StatusBadRequest, echo.MIMEApplicationJSON, `"code":"invalid_argument"`},
+		{"stream error", "/stream", "/stream?lastEventId=latest", "", http.StatusBadRequest, echo.MIMEApplicationJSON, `"code":"invalid_argument"`},
+		{"stream selector error", "/stream", "/stream?labelSelector=team%3D%3D%3D", "", http.StatusBadRequest, echo.MIMEApplicationJSON, `"code":"invalid_argument"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(e, http.MethodGet, apiV2+tt.target, tt.accept, "")
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, expected %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if contentType := rec.Header().Get(echo.HeaderContentType); !strings.HasPrefix(contentType, tt.contentType) {
+				t.Fatalf("content type %s, expected %s", contentType, tt.contentType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.body) {
+				t.Fatalf("body %q does not contain %q", rec.Body.String(), tt.body)
+			}
+			if err := validateResponse(spec, http.MethodGet, apiV2+tt.route, rec.Code, rec.Header().Get(echo.HeaderContentType), rec.Body.Bytes()); err != nil {
+				t.Fatalf("contract violation: %s", err)
+			}
+		})
+	}
+}
+
+// routing errors of the v2 api use the envelope too
+func TestAPIv2ErrorEnvelope(t *testing.T) {
+	e, _, spec := newTestAPI()
+	tests := []struct {
+		method, target string
+		status         int
+		code           string
+	}{
+		{http.MethodGet, "/unknown", http.StatusNotFound, `"code":"not_found"`},
+		{http.MethodPut, "/silences", http.StatusMethodNotAllowed, `"code":"method_not_allowed"`},
+	}
+	errorSchema := spec.Paths["/healthcheck/v2/status"]["get"].Responses["default"].Content[echo.MIMEApplicationJSON].Schema
+	for _, tt := range tests {
+		rec := serve(e, tt.method, apiV2+tt.target, "", "")
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.code) {
+			t.Fatalf("%s %s: status %d, body %s", tt.method, tt.target, rec.Code, rec.Body.String())
+		}
+		var value interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &value); err != nil {
+			t.Fatal(err)
+		}
+		if err := validateSchema(spec, errorSchema, value, "$"); err != nil {
+			t.Fatalf("%s %s: %s", tt.method, tt.target, err)
+		}
+	}
+}
+
+// the event stream is not buffered by the contract validator
+func TestContractValidatorSkipsEventStreams(t *testing.T) {
+	routes := apiRoutes()
+	spec := openAPISpec(routes)
+	e := echo.New()
+	validator := contractValidator(routes, spec)
+	flushed := make(chan bool, 1)
+	e.GET(apiV2+"/stream", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+		c.Response().WriteHeader(http.StatusOK)
+		c.Response().Write([]byte("event: snapshot\n\n"))
+		_, unwrapped := c.Response().Writer.(*httptest.ResponseRecorder)
+		flushed <- unwrapped
+		return nil
+	}, validator)
+	serve(e, http.MethodGet, apiV2+"/stream", "", "")
+	if !<-flushed {
+		t.Fatal("the event stream is wrapped by the body dump")
+	}
+}